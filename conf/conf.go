@@ -15,6 +15,11 @@
 //	"seg-dict" {
 //		"dict-file": "/path/to/dict-file",
 //		"stop-file": "/path/to/stopword-file"
+//	},
+//	"callback": {
+//		"secret": "shared-secret",
+//		"max-retries": 5,
+//		"retry-interval": 1
 //	}
 // }
 //
@@ -25,6 +30,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -41,6 +47,11 @@ var (
 			DictFile string `json:"dict-file"`
 			StopFile string `json:"stop-file"`
 		} `json:"seg-dict"`
+		Callback struct {
+			Secret        string `json:"secret"`         // 回调签名的密钥，为空则不签名
+			MaxRetries    int    `json:"max-retries"`    // 回调失败后的最大重试次数，缺省5，<0不重试
+			RetryInterval int    `json:"retry-interval"` // 首次重试前等待的秒数，缺省1，之后每次翻倍
+		} `json:"callback"`
 	}
 
 	// Loc 缺省时区，会被环境变量TZ覆盖
@@ -115,6 +126,14 @@ func checkMust() error {
 		return fmt.Errorf("listening port expected in conf")
 	}
 
+	cb := &ServiceConf.Callback
+	if cb.MaxRetries == 0 {
+		cb.MaxRetries = 5
+	}
+	if cb.RetryInterval <= 0 {
+		cb.RetryInterval = 1
+	}
+
 	if ServiceConf.RootDir == "" {
 		return fmt.Errorf("root-dir expected in conf")
 	}
@@ -126,14 +145,20 @@ func checkMust() error {
 		return fmt.Errorf("%s is not a directory", ServiceConf.RootDir)
 	}
 
-	/*
-		segDict := &ServiceConf.SegDict
-		if err := checkDict(segDict.DictFile, "seg-dict/dict-file"); err != nil {
-			return err
+	// 不配置词典时zh字段按单个汉字分词
+	segDict := &ServiceConf.SegDict
+	if segDict.DictFile != "" {
+		for _, dictFile := range strings.Split(segDict.DictFile, ",") {
+			if err := CheckDict(dictFile, "seg-dict/dict-file"); err != nil {
+				return err
+			}
 		}
-		if err := checkDict(segDict.StopFile, "seg-dict/stop-file"); err != nil {
-			return err
-		}*/
+		if segDict.StopFile != "" {
+			if err := CheckDict(segDict.StopFile, "seg-dict/stop-file"); err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
@@ -149,7 +174,11 @@ func CheckDict(path, prompt string) error {
 
 // 显示全局配置信息
 func DumpConf() {
-	fmt.Printf("conf: %v\n", ServiceConf)
+	c := ServiceConf
+	if c.Callback.Secret != "" {
+		c.Callback.Secret = "******"
+	}
+	fmt.Printf("conf: %v\n", c)
 	fmt.Printf("TZ time location: %v\n", Loc)
 	fmt.Printf("UseStore: %v\n", UseStore)
 }
@@ -3,12 +3,23 @@
 // {
 //    "name": "hello",
 //    "shards": 8,
+//    "normalize": ["lowercase", "nfkc", "halfwidth", "t2s"], // 规范化使用的字符过滤器，用于所有分词的字段、不指定字段的查询及过滤条件
+//    "char-filters": {   // 带参数的字符过滤器，"type"指明类型
+//        "cf1": {"type": "mapping", "mappings": {"-": ""}}
+//    },
+//    "token-filters": {  // 带参数的token过滤器，"type"指明类型
+//        "tf1": {"type": "length", "min": 2, "max": 20}
+//    },
+//    "analyzers": {      // 自定义的分析器，可以引用上面定义的过滤器及内置、注册的过滤器
+//        "a1": {"char-filters": ["cf1"], "tokenizer": "keyword", "token-filters": ["lowercase", "tf1"]}
+//    },
 //    "fields": [
 //        {
 //            "name": "f1",
 //            "pk": true|false, // 属于PK的字段一定会保存
 //            "type": "string"|"i8"|"u8"|...|"float"|"date"|"datetime"|"time"|"timestamp", // timestamp单位秒，是i64的别名
 //            "tokenizer": "zh"|"space"|"none"|null, // 分词器：中文、空白、不需要；只有字符串有效
+//            "analyzer": "a1", // 分析器，和tokenizer只能指定一个
 //            "time-fmt": "",    // 当type是date,datetime,time时的格式串，
 // 								 缺省分别为"YYYY-MM-DD", "YYYY-MM-DD HH:MM:SS", "HH:MM:SS"，可以精确到毫秒
 //            "sorting": "desc"|"asc"  // 参与没有排序条件时的缺省排序
@@ -72,14 +83,29 @@ type Field struct {
 	PK        bool   `json:"pk"`
 	Type      string `json:"type"`
 	TimeFmt   string `json:"time-fmt,omitempty"`
-	Tokenizer string `json:"tokenizer"`
+	Tokenizer string `json:"tokenizer,omitempty"`
+	Analyzer  string `json:"analyzer,omitempty"`
 	Sorting   string `json:"sorting,omitempty"`
 }
 
+// 自定义的分析器：先用字符过滤器处理字段值，再分词，最后用token过滤器依次处理token
+type AnalyzerConf struct {
+	CharFilters  []string `json:"char-filters,omitempty"`
+	Tokenizer    string   `json:"tokenizer"`
+	TokenFilters []string `json:"token-filters,omitempty"`
+}
+
+// 带参数的过滤器，"type"指明类型，其它是参数
+type FilterConf map[string]interface{}
+
 // schema字段列表
 type SchemaConf struct {
-	Shards uint16  `json:"shards"`
-	Fields []Field `json:"fields"`
+	Shards       uint16                   `json:"shards"`
+	Normalize    []string                 `json:"normalize,omitempty"`
+	CharFilters  map[string]FilterConf    `json:"char-filters,omitempty"`
+	TokenFilters map[string]FilterConf    `json:"token-filters,omitempty"`
+	Analyzers    map[string]*AnalyzerConf `json:"analyzers,omitempty"`
+	Fields       []Field                  `json:"fields"`
 }
 
 // 缺省排序列表
@@ -146,19 +172,24 @@ func LoadSchema(index string) (*Schema, error) {
 	}, nil
 }
 
-// 保存一个索引库的schema
+// 解析并检查一个索引库的schema
 //   index: 索引库名
-func SaveSchema(index string, in io.Reader) error {
+func ParseSchema(index string, in io.Reader) (*SchemaConf, error) {
 	schemaConf, err := parseSchema(in)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if _, _, _, _, _, err = checkSchemaConf(index, schemaConf); err != nil {
-		return err
+		return nil, err
 	}
+	return schemaConf, nil
+}
 
+// 保存一个索引库的schema
+//   index: 索引库名
+func SaveSchema(index string, schemaConf *SchemaConf) error {
 	d, p := generateSchemaFile(index)
-	if err = createDir(d); err != nil {
+	if err := createDir(d); err != nil {
 		return err
 	}
 	f, err := os.OpenFile(p, os.O_RDWR|os.O_CREATE, 0644)
@@ -210,6 +241,26 @@ func (field *Field) FormatDatetime(v interface{}) interface{} {
 	}
 }
 
+// 字段使用的分析器名，没有指定analyzer时为tokenizer
+func (field *Field) AnalyzerName() string {
+	if field.Analyzer != "" {
+		return field.Analyzer
+	}
+	return field.Tokenizer
+}
+
+// 是否是数值类型的字段
+func (field *Field) IsNumber() bool {
+	switch field.Type {
+	case "i8", "i16", "i32", "i64", "int", "integer", "timestamp",
+		"u8", "u16", "u32", "u64", "uint",
+		"f32", "f64", "float":
+		return true
+	default:
+		return false
+	}
+}
+
 // 根据字段类型把给定的字段值转换为相应的类型
 //    value:   需要转换的值
 // 返回的数据中已经是经过转换的数据
@@ -465,10 +516,15 @@ func checkSchemaConf(
 		case WsTokenizer:
 		case NoneTokenizer:
 		case "":
-			field.Tokenizer = WsTokenizer
+			if field.Analyzer == "" {
+				field.Tokenizer = WsTokenizer
+			}
 		default:
 			return nil, nil, nil, nil, false, fmt.Errorf("unknown tokenizer %s in field name %s", field.Tokenizer, field.Name)
 		}
+		if field.Analyzer != "" && field.Tokenizer != "" {
+			return nil, nil, nil, nil, false, fmt.Errorf("tokenizer and analyzer can not be both specified in field name %s", field.Name)
+		}
 
 		if field.PK {
 			pi = append(pi, i)
@@ -0,0 +1,75 @@
+// 索引库的同义词规则，保存在schema所在目录的synonyms.json中
+// 格式:
+// [
+//    "tv, television, telly",  // 同义词组，查询任何一个词时都查询组中所有的词
+//    "ipod, i-pod => ipod",     // 单向映射，查询左边的词时改为查询右边的词
+//    "# 以#开头的是注释"
+// ]
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 加载一个索引库的同义词规则，没有设置过同义词时返回nil
+//   index: 索引库名
+func LoadSynonyms(index string) ([]string, error) {
+	f, err := os.Open(generateSynonymsFile(index))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []string
+	if err = json.NewDecoder(f).Decode(&rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// 保存一个索引库的同义词规则，覆盖原来的规则
+//   index: 索引库名
+//   rules: 同义词规则，为空时删除同义词文件
+func SaveSynonyms(index string, rules []string) error {
+	d, _ := generateSchemaFile(index)
+	if fi, err := os.Stat(d); err != nil || !fi.IsDir() {
+		return fmt.Errorf("index %s not found", index)
+	}
+
+	p := generateSynonymsFile(index)
+	if len(rules) == 0 {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	// 先写临时文件再改名，避免写到一半时被读取
+	tmp := p + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(rules); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func generateSynonymsFile(index string) string {
+	d, _ := generateSchemaFile(index)
+	return fmt.Sprintf("%s/synonyms.json", d)
+}
@@ -3,9 +3,12 @@ module go-search
 go 1.12
 
 require (
-	github.com/go-ego/gse v0.0.0-20190923185659-b86c09691506 // indirect
+	github.com/go-ego/gpy v0.0.0-20181128170341-b6d42325845c
+	github.com/go-ego/gse v0.0.0-20190923185659-b86c09691506
 	github.com/go-ego/riot v0.0.0-20190802171934-6ed3775d67b6
 	github.com/hashicorp/golang-lru v0.5.3
+	github.com/kljensen/snowball v0.6.0
 	github.com/rosbit/go-wget v1.1.0
 	github.com/rosbit/http-helper v0.0.3
+	golang.org/x/text v0.3.2
 )
@@ -0,0 +1,323 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 聚合类型
+const (
+	aggRange         = "range"
+	aggHistogram     = "histogram"
+	aggDateHistogram = "date_histogram"
+	aggStats         = "stats"
+)
+
+// agg参数中的一个聚合，格式为"字段名:类型[:参数]"
+type aggSpec struct {
+	fieldName string
+	aggType   string
+	params    string
+}
+
+// range聚合的一个区间，包含from，不包含to
+type RangeBucket struct {
+	From  interface{} `json:"from,omitempty"`
+	To    interface{} `json:"to,omitempty"`
+	Count int         `json:"count"`
+}
+
+// histogram、date_histogram聚合的一个区间，key是区间的起始值
+type HistogramBucket struct {
+	Key   interface{} `json:"key"`
+	Count int         `json:"count"`
+}
+
+// stats聚合的结果，datetime字段没有sum
+type Stats struct {
+	Count int         `json:"count"`
+	Min   interface{} `json:"min"`
+	Max   interface{} `json:"max"`
+	Avg   interface{} `json:"avg"`
+	Sum   interface{} `json:"sum,omitempty"`
+}
+
+type aggField struct {
+	aggSpec
+	field *conf.Field
+
+	ranges   []scope // range的边界，已经转换为字段的类型
+	interval float64 // histogram的间隔
+	unit     string  // date_histogram的间隔: hour/day/week/month/year
+	buckets  []int   // range各区间的doc数
+	counts   map[float64]int
+	dates    map[int64]int
+	stats    Stats
+	sum      float64
+}
+
+// 统计所有匹配的doc中数值、时间字段的分布，打分函数会在多个shard中并发调用
+type aggregator struct {
+	lock sync.Mutex
+	aggs []aggField
+}
+
+func newAggregator(schema *indexSchema, specs []aggSpec) (*aggregator, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	aggs := make([]aggField, len(specs))
+	for i, spec := range specs {
+		fIdx, ok := schema.FieldMap[spec.fieldName]
+		if !ok {
+			return nil, fmt.Errorf("agg field %s not found", spec.fieldName)
+		}
+		field := &schema.Fields[fIdx]
+		isDate := field.Type == conf.DateType || field.Type == conf.DateTimeType
+		if !field.IsNumber() && !isDate && field.Type != conf.TimeType {
+			return nil, fmt.Errorf("agg field %s must be number or datetime", spec.fieldName)
+		}
+
+		a := &aggs[i]
+		a.aggSpec, a.field = spec, field
+		switch spec.aggType {
+		case aggRange:
+			for _, r := range fieldsKeepQuote(spec.params, ',') {
+				pos := strings.Index(r, "~")
+				if pos < 0 {
+					return nil, fmt.Errorf("bad range %s in agg %s", r, spec.fieldName)
+				}
+				var s scope
+				var err error
+				if s.from, err = aggBound(field, r[:pos]); err != nil {
+					return nil, err
+				}
+				if s.to, err = aggBound(field, r[pos+1:]); err != nil {
+					return nil, err
+				}
+				a.ranges = append(a.ranges, s)
+			}
+			if len(a.ranges) == 0 {
+				return nil, fmt.Errorf("ranges expected in agg %s", spec.fieldName)
+			}
+			a.buckets = make([]int, len(a.ranges))
+		case aggHistogram:
+			if !field.IsNumber() {
+				return nil, fmt.Errorf("histogram field %s must be number", spec.fieldName)
+			}
+			interval, err := strconv.ParseFloat(spec.params, 64)
+			if err != nil || interval <= 0 {
+				return nil, fmt.Errorf("bad interval %s in agg %s", spec.params, spec.fieldName)
+			}
+			a.interval = interval
+			a.params = strconv.FormatFloat(interval, 'f', -1, 64)
+			a.counts = map[float64]int{}
+		case aggDateHistogram:
+			if !isDate {
+				return nil, fmt.Errorf("date_histogram field %s must be date or datetime", spec.fieldName)
+			}
+			switch spec.params {
+			case "hour", "day", "week", "month", "year":
+				a.unit = spec.params
+			case "":
+				a.unit = "day"
+			default:
+				return nil, fmt.Errorf("bad interval %s in agg %s", spec.params, spec.fieldName)
+			}
+			a.dates = map[int64]int{}
+		case aggStats:
+		default:
+			return nil, fmt.Errorf("unknown agg type %s", spec.aggType)
+		}
+	}
+	return &aggregator{aggs: aggs}, nil
+}
+
+func aggBound(field *conf.Field, s string) (interface{}, error) {
+	if s = strings.TrimSpace(unquote(s)); s == "" {
+		return nil, nil
+	}
+	v, err := field.ToNativeValue(s)
+	if err != nil {
+		return nil, fmt.Errorf("bad range value %s for %s: %v", s, field.Name, err)
+	}
+	return v, nil
+}
+
+func (ag *aggregator) add(doc StoredDoc) {
+	ag.lock.Lock()
+	defer ag.lock.Unlock()
+
+	for i := range ag.aggs {
+		a := &ag.aggs[i]
+		v, ok := doc[a.fieldName]
+		if !ok || v == nil {
+			continue
+		}
+
+		switch a.aggType {
+		case aggRange:
+			for j := range a.ranges {
+				r := &a.ranges[j]
+				if (r.from == nil || compareNumber(v, r.from) >= 0) && (r.to == nil || compareNumber(v, r.to) < 0) {
+					a.buckets[j]++
+				}
+			}
+		case aggHistogram:
+			a.counts[math.Floor(toFloat64(v)/a.interval)*a.interval]++
+		case aggDateHistogram:
+			nsec, _ := v.(int64)
+			a.dates[truncateTime(nsec, a.unit)]++
+		case aggStats:
+			st := &a.stats
+			if st.Count == 0 || compareNumber(v, st.Min) < 0 {
+				st.Min = v
+			}
+			if st.Count == 0 || compareNumber(v, st.Max) > 0 {
+				st.Max = v
+			}
+			st.Count++
+			a.sum += toFloat64(v)
+		}
+	}
+}
+
+// 输出各聚合的结果，key为"字段名:类型"，histogram、date_histogram的key为"字段名:类型:间隔"
+func (ag *aggregator) result() map[string]interface{} {
+	ag.lock.Lock()
+	defer ag.lock.Unlock()
+
+	res := make(map[string]interface{}, len(ag.aggs))
+	for i := range ag.aggs {
+		a := &ag.aggs[i]
+		isNumber := a.field.IsNumber()
+		format := func(v interface{}) interface{} {
+			if v == nil || isNumber {
+				return v
+			}
+			return a.field.FormatDatetime(v)
+		}
+
+		var out interface{}
+		switch a.aggType {
+		case aggRange:
+			buckets := make([]RangeBucket, len(a.ranges))
+			for j, r := range a.ranges {
+				buckets[j] = RangeBucket{From: format(r.from), To: format(r.to), Count: a.buckets[j]}
+			}
+			out = buckets
+		case aggHistogram:
+			buckets := make([]HistogramBucket, 0, len(a.counts))
+			for k, c := range a.counts {
+				buckets = append(buckets, HistogramBucket{Key: k, Count: c})
+			}
+			sort.Slice(buckets, func(i, j int) bool { return buckets[i].Key.(float64) < buckets[j].Key.(float64) })
+			out = buckets
+		case aggDateHistogram:
+			keys := make([]int64, 0, len(a.dates))
+			for k := range a.dates {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+			buckets := make([]HistogramBucket, len(keys))
+			for j, k := range keys {
+				buckets[j] = HistogramBucket{Key: format(k), Count: a.dates[k]}
+			}
+			out = buckets
+		case aggStats:
+			st := a.stats
+			if st.Count > 0 {
+				avg := a.sum / float64(st.Count)
+				if isNumber {
+					st.Avg, st.Sum = avg, a.sum
+				} else {
+					st.Avg = format(int64(avg))
+				}
+			}
+			st.Min, st.Max = format(st.Min), format(st.Max)
+			out = st
+		}
+		key := a.fieldName + ":" + a.aggType
+		switch a.aggType {
+		case aggHistogram:
+			key += ":" + a.params
+		case aggDateHistogram:
+			key += ":" + a.unit
+		}
+		res[key] = out
+	}
+	return res
+}
+
+// 比较两个同类型的数值
+func compareNumber(a, b interface{}) int {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch a.(type) {
+	case int8, int16, int32, int64, int:
+		x, y := va.Int(), vb.Int()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case uint8, uint16, uint32, uint64, uint:
+		x, y := va.Uint(), vb.Uint()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	case float32, float64:
+		x, y := va.Float(), vb.Float()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+func toFloat64(v interface{}) float64 {
+	rv := reflect.ValueOf(v)
+	switch v.(type) {
+	case int8, int16, int32, int64, int:
+		return float64(rv.Int())
+	case uint8, uint16, uint32, uint64, uint:
+		return float64(rv.Uint())
+	case float32, float64:
+		return rv.Float()
+	default:
+		return 0
+	}
+}
+
+// 把时间按conf.Loc时区截断到所在小时、天、周(周一开始)、月、年的起始时间
+func truncateTime(nsec int64, unit string) int64 {
+	t := time.Unix(0, nsec).In(conf.Loc)
+	y, m, d := t.Date()
+	switch unit {
+	case "hour":
+		t = time.Date(y, m, d, t.Hour(), 0, 0, 0, conf.Loc)
+	case "week":
+		t = time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, conf.Loc)
+	case "month":
+		t = time.Date(y, m, 1, 0, 0, 0, 0, conf.Loc)
+	case "year":
+		t = time.Date(y, 1, 1, 0, 0, 0, 0, conf.Loc)
+	default:
+		t = time.Date(y, m, d, 0, 0, 0, 0, conf.Loc)
+	}
+	return t.UnixNano()
+}
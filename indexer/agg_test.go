@@ -0,0 +1,89 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func Test_parseAgg(t *testing.T) {
+	specs, err := parseAgg(`price:range:0~100,100~|ts:date_histogram:month|price:stats`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 || specs[0] != (aggSpec{"price", aggRange, "0~100,100~"}) ||
+		specs[1] != (aggSpec{"ts", aggDateHistogram, "month"}) || specs[2] != (aggSpec{"price", aggStats, ""}) {
+		t.Errorf("unexpected specs %+v", specs)
+	}
+	if specs, err = parseAgg(""); err != nil || specs != nil {
+		t.Errorf("empty agg should be nil, got %v, %v", specs, err)
+	}
+	for _, bad := range []string{"price", ":stats", "price:stats|ts"} {
+		if _, err = parseAgg(bad); err == nil {
+			t.Errorf("bad agg %s should be rejected", bad)
+		}
+	}
+}
+
+func Test_Query_aggs(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	agg := "price:range:0~100,100~500,500~|price:histogram:100|ts:date_histogram:month|price:stats|ts:stats"
+	res, err := Query(index, &QueryArgs{Agg: agg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ranges := res.Aggs["price:range"].([]RangeBucket)
+	if len(ranges) != 3 || ranges[0].Count != 1 || ranges[1].Count != 2 || ranges[2].Count != 1 || ranges[2].To != nil {
+		t.Errorf("unexpected ranges %+v", ranges)
+	}
+
+	histogram := res.Aggs["price:histogram:100"].([]HistogramBucket)
+	keys := []float64{0, 100, 300, 700}
+	if len(histogram) != len(keys) {
+		t.Fatalf("unexpected histogram %+v", histogram)
+	}
+	for i, key := range keys {
+		if histogram[i].Key != key || histogram[i].Count != 1 {
+			t.Errorf("unexpected histogram %+v", histogram)
+		}
+	}
+
+	months := res.Aggs["ts:date_histogram:month"].([]HistogramBucket)
+	if len(months) != 3 || months[0].Key != "2019-10-01 00:00:00" || months[0].Count != 2 || months[2].Key != "2019-12-01 00:00:00" {
+		t.Errorf("unexpected date histogram %+v", months)
+	}
+
+	stats := res.Aggs["price:stats"].(Stats)
+	if stats.Count != 4 || stats.Min != float32(50) || stats.Max != float32(700) || stats.Avg != float64(300) || stats.Sum != float64(1200) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	tsStats := res.Aggs["ts:stats"].(Stats)
+	if tsStats.Min != "2019-10-01 10:00:00" || tsStats.Max != "2019-12-04 10:00:00" || tsStats.Sum != nil {
+		t.Errorf("unexpected datetime stats %+v", tsStats)
+	}
+
+	// 只统计满足条件的doc
+	if res, err = Query(index, &QueryArgs{Q: "shoes", Agg: "price:stats"}); err != nil {
+		t.Fatal(err)
+	}
+	if stats = res.Aggs["price:stats"].(Stats); stats.Count != 2 || stats.Sum != float64(200) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if res, err = Query(index, &QueryArgs{Q: "nothing", Agg: "price:stats"}); err != nil {
+		t.Fatal(err)
+	}
+	if stats = res.Aggs["price:stats"].(Stats); stats.Count != 0 || stats.Avg != nil {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	for _, bad := range []string{
+		"unknown:stats", "name:stats", "price:unknown",
+		"price:range:", "price:range:100", "price:range:x~",
+		"price:histogram:0", "ts:histogram:10", "price:date_histogram:day", "ts:date_histogram:minute",
+	} {
+		if _, err = Query(index, &QueryArgs{Agg: bad}); err == nil {
+			t.Errorf("bad agg %s should be rejected", bad)
+		}
+	}
+}
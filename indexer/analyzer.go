@@ -0,0 +1,309 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// 分析器，把字符串字段的值转换为token，建索引和查询时使用同一个分析器
+type Analyzer interface {
+	Analyze(s string) []string
+}
+
+// 字符过滤器，分词前处理字段值
+type CharFilter interface {
+	FilterChars(s string) string
+}
+
+// 分词器
+type Tokenizer interface {
+	Tokenize(s string) []string
+}
+
+// token过滤器，分词后处理token，可以修改、删除或增加token，可以直接修改传入的tokens
+type TokenFilter interface {
+	FilterTokens(tokens []string) []string
+}
+
+// 用函数实现的分析器、字符过滤器、分词器、token过滤器
+type AnalyzerFunc func(s string) []string
+type CharFilterFunc func(s string) string
+type TokenizerFunc func(s string) []string
+type TokenFilterFunc func(tokens []string) []string
+
+func (f AnalyzerFunc) Analyze(s string) []string                { return f(s) }
+func (f CharFilterFunc) FilterChars(s string) string            { return f(s) }
+func (f TokenizerFunc) Tokenize(s string) []string              { return f(s) }
+func (f TokenFilterFunc) FilterTokens(tokens []string) []string { return f(tokens) }
+
+// 由字符过滤器、分词器、token过滤器组成的分析器
+type chainAnalyzer struct {
+	charFilters  []CharFilter
+	tokenizer    Tokenizer
+	tokenFilters []TokenFilter
+}
+
+// 生成依次使用字符过滤器、分词器、token过滤器的分析器
+func NewAnalyzer(charFilters []CharFilter, tokenizer Tokenizer, tokenFilters []TokenFilter) Analyzer {
+	return &chainAnalyzer{charFilters: charFilters, tokenizer: tokenizer, tokenFilters: tokenFilters}
+}
+
+func (a *chainAnalyzer) Analyze(s string) []string {
+	for _, f := range a.charFilters {
+		s = f.FilterChars(s)
+	}
+	tokens := a.tokenizer.Tokenize(s)
+	for _, f := range a.tokenFilters {
+		if len(tokens) == 0 {
+			break
+		}
+		tokens = f.FilterTokens(tokens)
+	}
+	return tokens
+}
+
+// 全局注册的分析器及其组成部分，schema中可以按名称引用
+var (
+	analyzers = map[string]Analyzer{
+		conf.ZhTokenizer: NewAnalyzer(nil, TokenizerFunc(zhTokenizer), nil),
+		conf.WsTokenizer: NewAnalyzer(nil, TokenizerFunc(spaceTokenizer), nil),
+		"keyword":        NewAnalyzer(nil, TokenizerFunc(keywordTokenize), nil),
+		// 英文: 按空白和标点分词，转为小写，去掉停用词，提取词干
+		"english": NewAnalyzer(nil, TokenizerFunc(spaceTokenizer), []TokenFilter{
+			TokenFilterFunc(lowercaseTokens),
+			newStopFilter(stopWordLists["english"]),
+			mustTokenFilter(newStemFilter("english")),
+		}),
+	}
+	tokenizers = map[string]Tokenizer{
+		conf.ZhTokenizer: TokenizerFunc(zhTokenizer),
+		conf.WsTokenizer: TokenizerFunc(spaceTokenizer),
+		"keyword":        TokenizerFunc(keywordTokenize),
+	}
+	charFilters = map[string]CharFilter{
+		"html_strip": CharFilterFunc(stripHTML),
+		"lowercase":  CharFilterFunc(strings.ToLower),
+		"nfkc":       CharFilterFunc(norm.NFKC.String),
+		"halfwidth":  CharFilterFunc(width.Narrow.String),
+		"t2s":        CharFilterFunc(toSimplified),
+	}
+	tokenFilters = map[string]TokenFilter{
+		"lowercase":    TokenFilterFunc(lowercaseTokens),
+		"asciifolding": TokenFilterFunc(foldASCIITokens),
+		"english_stop": newStopFilter(stopWordLists["english"]),
+		"porter_stem":  mustTokenFilter(newStemFilter("english")),
+	}
+	registryLock sync.RWMutex
+)
+
+// 注册分析器，schema中的字段可以用"analyzer"引用
+func RegisterAnalyzer(name string, a Analyzer) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := analyzers[name]; ok || name == conf.NoneTokenizer {
+		return fmt.Errorf("analyzer %s exists", name)
+	}
+	analyzers[name] = a
+	return nil
+}
+
+// 注册分词器，schema中定义分析器时可以引用
+func RegisterTokenizer(name string, t Tokenizer) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := tokenizers[name]; ok {
+		return fmt.Errorf("tokenizer %s exists", name)
+	}
+	tokenizers[name] = t
+	return nil
+}
+
+// 注册字符过滤器，schema中定义分析器时可以引用
+func RegisterCharFilter(name string, f CharFilter) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := charFilters[name]; ok {
+		return fmt.Errorf("char filter %s exists", name)
+	}
+	charFilters[name] = f
+	return nil
+}
+
+// 注册token过滤器，schema中定义分析器时可以引用
+func RegisterTokenFilter(name string, f TokenFilter) error {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := tokenFilters[name]; ok {
+		return fmt.Errorf("token filter %s exists", name)
+	}
+	tokenFilters[name] = f
+	return nil
+}
+
+// 索引库的schema，以及按schema配置生成的各字段分析器及规范化函数
+type indexSchema struct {
+	*conf.Schema
+	analyzers []Analyzer          // 各字段的分析器，tokenizer为none的字段为nil
+	normalize func(string) string // 规范化函数，没有配置normalize时为nil
+}
+
+func newIndexSchema(schema *conf.Schema) (*indexSchema, error) {
+	analyzers, normalize, err := buildAnalyzers(schema.SchemaConf)
+	if err != nil {
+		return nil, err
+	}
+	return &indexSchema{Schema: schema, analyzers: analyzers, normalize: normalize}, nil
+}
+
+// 生成schema中各字段的分析器及规范化函数，字段引用的分析器先在schema中找，再在注册的分析器中找
+// 配置了normalize时，各字段的分析器先规范化字段值
+func buildAnalyzers(schemaConf *conf.SchemaConf) ([]Analyzer, func(string) string, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	cfs := make(map[string]CharFilter, len(schemaConf.CharFilters))
+	for name, fc := range schemaConf.CharFilters {
+		f, err := newCharFilter(fc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("char filter %s: %v", name, err)
+		}
+		cfs[name] = f
+	}
+	tfs := make(map[string]TokenFilter, len(schemaConf.TokenFilters))
+	for name, fc := range schemaConf.TokenFilters {
+		f, err := newTokenFilter(fc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("token filter %s: %v", name, err)
+		}
+		tfs[name] = f
+	}
+
+	var normalize func(string) string
+	if len(schemaConf.Normalize) > 0 {
+		var normalizers []CharFilter
+		for _, name := range schemaConf.Normalize {
+			f, ok := cfs[name]
+			if !ok {
+				if f, ok = charFilters[name]; !ok {
+					return nil, nil, fmt.Errorf("char filter %s in normalize not found", name)
+				}
+			}
+			normalizers = append(normalizers, f)
+		}
+		normalize = func(s string) string {
+			for _, f := range normalizers {
+				s = f.FilterChars(s)
+			}
+			return s
+		}
+	}
+
+	defined := make(map[string]Analyzer, len(schemaConf.Analyzers))
+	for name, ac := range schemaConf.Analyzers {
+		if name == conf.NoneTokenizer {
+			return nil, nil, fmt.Errorf("analyzer name %s is reserved", name)
+		}
+		a, err := newChainAnalyzer(ac, cfs, tfs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("analyzer %s: %v", name, err)
+		}
+		defined[name] = a
+	}
+
+	res := make([]Analyzer, len(schemaConf.Fields))
+	for i := range schemaConf.Fields {
+		field := &schemaConf.Fields[i]
+		name := field.AnalyzerName()
+		if name == conf.NoneTokenizer {
+			continue
+		}
+		a, ok := defined[name]
+		if !ok {
+			if a, ok = analyzers[name]; !ok {
+				return nil, nil, fmt.Errorf("analyzer %s of field %s not found", name, field.Name)
+			}
+		}
+		if normalize != nil {
+			a = NewAnalyzer([]CharFilter{CharFilterFunc(normalize)}, TokenizerFunc(a.Analyze), nil)
+		}
+		res[i] = a
+	}
+	return res, normalize, nil
+}
+
+func newChainAnalyzer(ac *conf.AnalyzerConf, cfs map[string]CharFilter, tfs map[string]TokenFilter) (Analyzer, error) {
+	if ac == nil {
+		return nil, fmt.Errorf("no definition")
+	}
+	a := &chainAnalyzer{}
+	for _, name := range ac.CharFilters {
+		f, ok := cfs[name]
+		if !ok {
+			if f, ok = charFilters[name]; !ok {
+				return nil, fmt.Errorf("char filter %s not found", name)
+			}
+		}
+		a.charFilters = append(a.charFilters, f)
+	}
+	name := ac.Tokenizer
+	if name == "" {
+		name = conf.WsTokenizer
+	}
+	t, ok := tokenizers[name]
+	if !ok {
+		return nil, fmt.Errorf("tokenizer %s not found", name)
+	}
+	a.tokenizer = t
+	for _, name := range ac.TokenFilters {
+		f, ok := tfs[name]
+		if !ok {
+			if f, ok = tokenFilters[name]; !ok {
+				return nil, fmt.Errorf("token filter %s not found", name)
+			}
+		}
+		a.tokenFilters = append(a.tokenFilters, f)
+	}
+	return a, nil
+}
+
+// 字段的分析器，tokenizer为none的字段返回nil
+func fieldAnalyzer(schema *indexSchema, fIdx int) Analyzer {
+	if fIdx < len(schema.analyzers) {
+		return schema.analyzers[fIdx]
+	}
+	// 没有经过newIndexSchema()生成分析器的schema
+	name := schema.Fields[fIdx].AnalyzerName()
+	if name == conf.NoneTokenizer {
+		return nil
+	}
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	if a, ok := analyzers[name]; ok {
+		return a
+	}
+	return analyzers[conf.WsTokenizer]
+}
+
+// 用schema的normalize规范化字符串，没有配置时不变
+func normalizeText(schema *indexSchema, s string) string {
+	if schema.normalize == nil {
+		return s
+	}
+	return schema.normalize(s)
+}
+
+func zhTokenizer(s string) []string    { return zhTokenize(s) }
+func spaceTokenizer(s string) []string { return whitespaceTokenize(s) }
+
+// 整个字段值作为一个token
+func keywordTokenize(s string) []string {
+	if s = strings.TrimSpace(s); s == "" {
+		return []string{}
+	}
+	return []string{s}
+}
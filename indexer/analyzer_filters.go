@@ -0,0 +1,207 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kljensen/snowball"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// 去掉HTML标签，转换HTML实体
+func stripHTML(s string) string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(s, " "))
+}
+
+func lowercaseTokens(tokens []string) []string {
+	for i, t := range tokens {
+		tokens[i] = strings.ToLower(t)
+	}
+	return tokens
+}
+
+// 去掉拉丁字母的变音符号，如"café" => "cafe"
+func foldASCIITokens(tokens []string) []string {
+	for i, t := range tokens {
+		if isASCII(t) {
+			continue
+		}
+		folder := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if s, _, err := transform.String(folder, t); err == nil {
+			tokens[i] = s
+		}
+	}
+	return tokens
+}
+
+// 生成按language提取词干的token过滤器，如english的"running" => "run"，"shoes" => "shoe"
+// 词干提取后的token是小写的
+func newStemFilter(language string) (TokenFilter, error) {
+	if _, err := snowball.Stem("a", language, true); err != nil {
+		return nil, fmt.Errorf("stemmer of language %s not supported", language)
+	}
+	return TokenFilterFunc(func(tokens []string) []string {
+		for i, t := range tokens {
+			if s, err := snowball.Stem(t, language, true); err == nil && s != "" {
+				tokens[i] = s
+			}
+		}
+		return tokens
+	}), nil
+}
+
+// 生成去掉停用词的token过滤器，停用词不区分大小写
+func newStopFilter(words []string) TokenFilter {
+	stops := make(map[string]bool, len(words))
+	for _, w := range words {
+		stops[strings.ToLower(w)] = true
+	}
+	return TokenFilterFunc(func(tokens []string) []string {
+		res := tokens[:0]
+		for _, t := range tokens {
+			if !stops[strings.ToLower(t)] {
+				res = append(res, t)
+			}
+		}
+		return res
+	})
+}
+
+func mustTokenFilter(f TokenFilter, err error) TokenFilter {
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// 根据schema中char-filters的定义生成字符过滤器
+//
+//	mapping: {"type": "mapping", "mappings": {"from": "to", ...}} 替换字符串
+func newCharFilter(fc conf.FilterConf) (CharFilter, error) {
+	switch fc["type"] {
+	case "mapping":
+		mappings, ok := fc["mappings"].(map[string]interface{})
+		if !ok || len(mappings) == 0 {
+			return nil, fmt.Errorf("mappings must be a non-empty object")
+		}
+		oldnew := make([]string, 0, len(mappings)*2)
+		for from, to := range mappings {
+			if from == "" {
+				return nil, fmt.Errorf("empty string can not be mapped")
+			}
+			oldnew = append(oldnew, from, fmt.Sprintf("%v", to))
+		}
+		return CharFilterFunc(strings.NewReplacer(oldnew...).Replace), nil
+	default:
+		return nil, fmt.Errorf("unknown type %v", fc["type"])
+	}
+}
+
+// 根据schema中token-filters的定义生成token过滤器
+//
+//	length: {"type": "length", "min": 2, "max": 20} 去掉字符数不在范围内的token，min、max可选
+//	stop: {"type": "stop", "words": ["a", "the", ...], "language": "english"} 去掉停用词，words、language至少指定一个
+//	stemmer: {"type": "stemmer", "language": "english"} 提取词干
+func newTokenFilter(fc conf.FilterConf) (TokenFilter, error) {
+	switch fc["type"] {
+	case "length":
+		min, err := intParam(fc, "min", 0)
+		if err != nil {
+			return nil, err
+		}
+		max, err := intParam(fc, "max", 0)
+		if err != nil {
+			return nil, err
+		}
+		return TokenFilterFunc(func(tokens []string) []string {
+			res := tokens[:0]
+			for _, t := range tokens {
+				l := utf8.RuneCountInString(t)
+				if l >= min && (max <= 0 || l <= max) {
+					res = append(res, t)
+				}
+			}
+			return res
+		}), nil
+	case "stop":
+		var stops []string
+		if w, ok := fc["words"]; ok {
+			words, ok := w.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("words must be an array")
+			}
+			for _, w := range words {
+				stops = append(stops, fmt.Sprintf("%v", w))
+			}
+		}
+		if l, ok := fc["language"]; ok {
+			language, _ := l.(string)
+			words, ok := stopWordLists[language]
+			if !ok {
+				return nil, fmt.Errorf("stop words of language %v not found", l)
+			}
+			stops = append(stops, words...)
+		}
+		if len(stops) == 0 {
+			return nil, fmt.Errorf("words or language must be specified")
+		}
+		return newStopFilter(stops), nil
+	case "stemmer":
+		language, ok := fc["language"].(string)
+		if !ok {
+			return nil, fmt.Errorf("language must be a string")
+		}
+		return newStemFilter(language)
+	default:
+		return nil, fmt.Errorf("unknown type %v", fc["type"])
+	}
+}
+
+func intParam(fc conf.FilterConf, name string, defVal int) (int, error) {
+	v, ok := fc[name]
+	if !ok {
+		return defVal, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return int(f), nil
+}
+
+var t2sMap = func() map[rune]rune {
+	from, to := []rune(t2sFrom), []rune(t2sTo)
+	m := make(map[rune]rune, len(from))
+	for i, r := range from {
+		m[r] = to[i]
+	}
+	return m
+}()
+
+// 繁体字转为简体字
+func toSimplified(s string) string {
+	return strings.Map(func(r rune) rune {
+		if sr, ok := t2sMap[r]; ok {
+			return sr
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,105 @@
+package indexer
+
+import (
+	"go-search/conf"
+	"strings"
+	"testing"
+)
+
+func Test_buildAnalyzers(t *testing.T) {
+	schemaConf := &conf.SchemaConf{
+		CharFilters: map[string]conf.FilterConf{
+			"nodash": {"type": "mapping", "mappings": map[string]interface{}{"-": ""}},
+		},
+		TokenFilters: map[string]conf.FilterConf{
+			"len2": {"type": "length", "min": float64(2)},
+			"stop": {"type": "stop", "words": []interface{}{"the"}},
+		},
+		Analyzers: map[string]*conf.AnalyzerConf{
+			"sku":   {CharFilters: []string{"nodash"}, Tokenizer: "keyword", TokenFilters: []string{"lowercase"}},
+			"title": {CharFilters: []string{"html_strip"}, TokenFilters: []string{"lowercase", "asciifolding", "len2", "stop"}},
+		},
+		Fields: []conf.Field{
+			{Name: "id", Tokenizer: conf.NoneTokenizer},
+			{Name: "sku", Analyzer: "sku"},
+			{Name: "title", Analyzer: "title"},
+			{Name: "tags", Tokenizer: conf.WsTokenizer},
+		},
+	}
+	analyzers, normalize, err := buildAnalyzers(schemaConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analyzers[0] != nil {
+		t.Errorf("field with tokenizer none should have no analyzer")
+	}
+	if normalize != nil {
+		t.Errorf("normalize should be nil without configuration")
+	}
+	cases := []struct {
+		fIdx   int
+		s      string
+		tokens string
+	}{
+		{1, " AB-123 ", "ab123"},
+		{2, "<b>Café</b> of the Year x", "cafe|of|year"},
+		{3, "a B", "a|B"},
+	}
+	for _, c := range cases {
+		if tokens := strings.Join(analyzers[c.fIdx].Analyze(c.s), "|"); tokens != c.tokens {
+			t.Errorf("%s: expected %s, got %s", c.s, c.tokens, tokens)
+		}
+	}
+
+	schemaConf.Analyzers["title"].TokenFilters = []string{"unknown"}
+	if _, _, err = buildAnalyzers(schemaConf); err == nil {
+		t.Errorf("unknown token filter should be rejected")
+	}
+}
+
+func Test_stemmer(t *testing.T) {
+	if tokens := strings.Join(analyzers["english"].Analyze("Running Shoes of the Year"), "|"); tokens != "run|shoe|year" {
+		t.Errorf("unexpected tokens %s", tokens)
+	}
+
+	f, err := newTokenFilter(conf.FilterConf{"type": "stop", "language": "french"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens := strings.Join(f.FilterTokens([]string{"Le", "chat"}), "|"); tokens != "chat" {
+		t.Errorf("unexpected tokens %s", tokens)
+	}
+	if _, err = newTokenFilter(conf.FilterConf{"type": "stemmer", "language": "klingon"}); err == nil {
+		t.Errorf("unknown language should be rejected")
+	}
+}
+
+func Test_normalize(t *testing.T) {
+	schemaConf := &conf.SchemaConf{
+		Normalize: []string{"nfkc", "lowercase", "t2s"},
+		Fields: []conf.Field{
+			{Name: "id", Tokenizer: conf.NoneTokenizer},
+			{Name: "tags", Tokenizer: conf.WsTokenizer},
+		},
+	}
+	analyzers, normalize, err := buildAnalyzers(schemaConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := normalize("ＡＰＰＬＥ 蘋果，Apple"); s != "apple 苹果,apple" {
+		t.Errorf("unexpected normalized string %q", s)
+	}
+	if tokens := strings.Join(analyzers[1].Analyze("ＡＢＣ 愛"), "|"); tokens != "abc|爱" {
+		t.Errorf("unexpected tokens %s", tokens)
+	}
+
+	schema := &indexSchema{Schema: &conf.Schema{SchemaConf: schemaConf}, analyzers: analyzers, normalize: normalize}
+	if !condEquals("ＡＢＣ", "abc", schema, 0) || !condEquals("Abc 臺灣", "台湾", schema, 1) {
+		t.Errorf("filter conditions should be normalized")
+	}
+
+	schemaConf.Normalize = []string{"unknown"}
+	if _, _, err = buildAnalyzers(schemaConf); err == nil {
+		t.Errorf("unknown char filter in normalize should be rejected")
+	}
+}
@@ -0,0 +1,293 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// 批量操作的类型
+const (
+	BulkIndex  = "index"
+	BulkUpdate = "update"
+	BulkUpsert = "upsert"
+	BulkDelete = "delete"
+)
+
+// 批量操作中的一项，NDJSON中的一行
+type BulkAction struct {
+	Index     string                 `json:"index"`
+	Op        string                 `json:"op"`
+	Id        interface{}            `json:"id"`  // delete时的docID
+	Doc       map[string]interface{} `json:"doc"` // index/update/upsert的doc，update/upsert可以使用$set/$inc等操作符
+	IfVersion *uint64                `json:"if_version"`
+}
+
+// 一项操作的结果
+type BulkResult struct {
+	Index   string `json:"index,omitempty"`
+	Op      string `json:"op,omitempty"`
+	Id      string `json:"id,omitempty"`
+	Version uint64 `json:"version,omitempty"`
+	Status  int    `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// 一个索引库在批量操作中写入过的doc，后面的操作要基于这些还没有flush的doc
+type bulkIndex struct {
+	idx     *indexer
+	docs    map[string]map[string]interface{} // docID -> doc，被删除的doc为nil
+	stored  map[string]StoredDoc              // 批量操作开始时一次取出的doc
+	pending map[string]chan struct{}          // docID -> 最近一次操作完成时关闭
+	indexed int
+	deleted int
+}
+
+// doc的当前内容，优先使用批量操作中写入过的doc
+func (b *bulkIndex) current(docId string) map[string]interface{} {
+	if doc, ok := b.docs[docId]; ok {
+		return doc
+	}
+	storedDoc, ok := b.stored[docId]
+	if !ok {
+		return nil
+	}
+	return b.idx.editableDoc(storedDoc)
+}
+
+// 需要读取doc当前内容的操作的docID，不需要时返回false
+func (b *bulkIndex) lookupId(action *BulkAction) (string, bool) {
+	switch action.Op {
+	case BulkDelete:
+		if action.IfVersion == nil || action.Id == nil {
+			return "", false
+		}
+		return fmt.Sprintf("%v", action.Id), true
+	case BulkIndex:
+		if action.IfVersion == nil {
+			return "", false
+		}
+	case BulkUpdate, BulkUpsert:
+	default:
+		return "", false
+	}
+	// $set等操作符不是字段名，不影响docID
+	docId, err := b.idx.docIDOf(action.Doc)
+	return docId, err == nil
+}
+
+// 同一个doc的操作需要按顺序执行，等待前一个操作完成
+func (b *bulkIndex) wait(docId string) chan struct{} {
+	if done, ok := b.pending[docId]; ok {
+		<-done
+	}
+	done := make(chan struct{})
+	b.pending[docId] = done
+	return done
+}
+
+// 执行NDJSON格式的批量操作，每行一个BulkAction，返回每一项的结果
+// 所有操作都交给opThread执行，最后每个索引库只flush一次
+func Bulk(in io.Reader) ([]BulkResult, error) {
+	if !running {
+		return nil, fmt.Errorf("the service is stopped")
+	}
+
+	var actions []*BulkAction
+	var results []BulkResult
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			action := &BulkAction{}
+			if e := json.Unmarshal(line, action); e != nil {
+				results = append(results, BulkResult{Status: http.StatusBadRequest, Error: e.Error()})
+				action = nil
+			} else {
+				results = append(results, BulkResult{Index: action.Index, Op: action.Op})
+			}
+			actions = append(actions, action)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 涉及的索引库按名称顺序加锁，避免并发的批量操作死锁
+	indexes := map[string]*bulkIndex{}
+	for i, action := range actions {
+		if action == nil {
+			continue
+		}
+		if _, ok := indexes[action.Index]; ok {
+			continue
+		}
+		idx, err := initIndexer(action.Index)
+		if err != nil {
+			results[i].Status, results[i].Error = http.StatusNotFound, err.Error()
+			actions[i] = nil
+			continue
+		}
+		indexes[action.Index] = &bulkIndex{idx: idx, docs: map[string]map[string]interface{}{}, pending: map[string]chan struct{}{}}
+	}
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx := indexes[name].idx
+		idx.updateLock.Lock()
+		defer idx.updateLock.Unlock()
+	}
+
+	// 需要doc当前内容的操作，每个索引库一次取出所有的doc
+	lookupIds := map[string][]string{}
+	for _, action := range actions {
+		if action == nil {
+			continue
+		}
+		if docId, ok := indexes[action.Index].lookupId(action); ok {
+			lookupIds[action.Index] = append(lookupIds[action.Index], docId)
+		}
+	}
+	for name, b := range indexes {
+		b.stored = b.idx.lookupDocs(lookupIds[name])
+	}
+
+	for i, action := range actions {
+		if action == nil {
+			continue
+		}
+		res := &results[i]
+		if err := indexes[action.Index].do(action, res); err != nil {
+			res.Status, res.Error = http.StatusBadRequest, err.Error()
+			switch err.(type) {
+			case internalError:
+				res.Status = http.StatusInternalServerError
+			}
+			switch err {
+			case ErrVersionConflict:
+				res.Status = http.StatusConflict
+			case ErrDocNotFound:
+				res.Status = http.StatusNotFound
+			}
+			continue
+		}
+		res.Status = http.StatusOK
+	}
+
+	// 所有操作生效后才能解锁
+	for _, name := range names {
+		b := indexes[name]
+		if len(b.pending) == 0 {
+			continue
+		}
+		for _, done := range b.pending {
+			<-done
+		}
+		b.idx.flushSync()
+		log.Printf("[info] %d docs indexed, %d docs deleted in index %s by bulk\n", b.indexed, b.deleted, name)
+	}
+	return results, nil
+}
+
+func (b *bulkIndex) do(action *BulkAction, res *BulkResult) error {
+	idx := b.idx
+	switch action.Op {
+	case BulkDelete:
+		if action.Id == nil {
+			return fmt.Errorf("id must be specified")
+		}
+		docId := fmt.Sprintf("%v", action.Id)
+		res.Id = docId
+		if action.IfVersion != nil {
+			if err := checkVersion(b.current(docId), action.IfVersion); err != nil {
+				return err
+			}
+		}
+		idx.sendDeleteOp(docId, b.wait(docId))
+		b.docs[docId] = nil
+		b.deleted++
+		return nil
+	case BulkIndex, BulkUpdate, BulkUpsert:
+	default:
+		return fmt.Errorf("unknown op %s", action.Op)
+	}
+
+	if action.Doc == nil {
+		return fmt.Errorf("doc must be specified")
+	}
+	fields, updates := action.Doc, &fieldUpdates{}
+	if action.Op != BulkIndex {
+		var err error
+		if fields, updates, err = parseUpdateOps(action.Doc); err != nil {
+			return err
+		}
+		if err = updates.check(idx.schema); err != nil {
+			return err
+		}
+	}
+	docId, err := idx.docIDOf(fields)
+	if err != nil {
+		return err
+	}
+	res.Id = docId
+
+	doc := fields
+	if action.Op == BulkIndex {
+		if action.IfVersion != nil {
+			if err = checkVersion(b.current(docId), action.IfVersion); err != nil {
+				return err
+			}
+		}
+	} else {
+		existingDoc := b.current(docId)
+		if err = checkVersion(existingDoc, action.IfVersion); err != nil {
+			return err
+		}
+		if existingDoc == nil {
+			if action.Op != BulkUpsert {
+				return ErrDocNotFound
+			}
+			existingDoc = map[string]interface{}{}
+		} else {
+			// 不能修改批量操作中保存的doc
+			copied := make(map[string]interface{}, len(existingDoc))
+			for k, v := range existingDoc {
+				copied[k] = v
+			}
+			existingDoc = copied
+		}
+		for k, v := range fields {
+			existingDoc[k] = v
+		}
+		if err = updates.apply(existingDoc, idx.schema); err != nil {
+			return err
+		}
+		doc = existingDoc
+	}
+
+	done := b.wait(docId)
+	if _, res.Version, err = idx.sendIndexOp(doc, done); err != nil {
+		// 操作没有发出，不需要等待
+		close(done)
+		return err
+	}
+	saved := make(map[string]interface{}, len(doc)+1)
+	for k, v := range doc {
+		saved[k] = v
+	}
+	saved[VersionField] = res.Version
+	b.docs[docId] = saved
+	b.indexed++
+	return nil
+}
@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_Bulk(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	docs, _ := GetDocs(index, []string{"2"}, "")
+	v2 := docs["2"][VersionField]
+
+	ndjson := strings.Join([]string{
+		`{"index": "` + index + `", "op": "index", "doc": {"id": 5, "name": "new", "price": 10}}`,
+		`{"index": "` + index + `", "op": "update", "doc": {"id": 5, "$inc": {"price": 5}}}`,
+		`{"index": "` + index + `", "op": "update", "doc": {"id": 1, "name": "red boots"}}`,
+		`{"index": "` + index + `", "op": "delete", "id": 3}`,
+		`{"index": "` + index + `", "op": "update", "doc": {"id": 3, "name": "gone"}}`,
+		`{"index": "` + index + `", "op": "upsert", "doc": {"id": 6, "name": "upserted"}}`,
+		`{"index": "` + index + `", "op": "delete", "id": 2, "if_version": 1}`,
+		`{"index": "no-such-index", "op": "delete", "id": 1}`,
+		`{"index": "` + index + `", "op": "unknown"}`,
+		`not json`,
+	}, "\n")
+	results, err := Bulk(strings.NewReader(ndjson))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []int{
+		http.StatusOK, http.StatusOK, http.StatusOK, http.StatusOK,
+		http.StatusNotFound, // 前面已经删除
+		http.StatusOK,
+		http.StatusConflict,
+		http.StatusNotFound, // 索引库不存在
+		http.StatusBadRequest,
+		http.StatusBadRequest,
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, status := range expected {
+		if results[i].Status != status {
+			t.Errorf("item #%d: expected status %d, got %d (%s)", i, status, results[i].Status, results[i].Error)
+		}
+	}
+	if results[1].Version <= results[0].Version {
+		t.Errorf("versions of the same doc should increase: %d, %d", results[0].Version, results[1].Version)
+	}
+
+	// 返回时所有操作都已生效
+	docs, _ = GetDocs(index, []string{"1", "2", "3", "5", "6"}, "")
+	if docs["5"]["price"] != float32(15) {
+		t.Errorf("update should apply on the doc indexed earlier in the same bulk, got %v", docs["5"])
+	}
+	if docs["1"]["name"] != "red boots" || docs["1"]["brand"] != "acme" {
+		t.Errorf("unexpected doc 1 %v", docs["1"])
+	}
+	if _, ok := docs["3"]; ok {
+		t.Errorf("doc 3 should be deleted")
+	}
+	if docs["2"][VersionField] != v2 {
+		t.Errorf("doc 2 should be unchanged after version conflict")
+	}
+	if docs["6"]["name"] != "upserted" {
+		t.Errorf("unexpected doc 6 %v", docs["6"])
+	}
+}
@@ -0,0 +1,158 @@
+package indexer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-search/conf"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rosbit/go-wget"
+)
+
+// 回调的投递状态
+const (
+	CallbackPending   = "pending"
+	CallbackDelivered = "delivered"
+	CallbackFailed    = "failed"
+)
+
+// 回调请求的头
+const (
+	CallbackJobHeader       = "X-Go-Search-Job"
+	CallbackTimestampHeader = "X-Go-Search-Timestamp"
+	CallbackSignatureHeader = "X-Go-Search-Signature" // sha256=<hex(HMAC-SHA256(secret, timestamp + "." + body))>
+)
+
+const (
+	maxRetryInterval = 5 * time.Minute // 重试间隔的上限
+	maxErrorContent  = 200             // 投递记录中保存的回调接口返回内容的最大长度
+)
+
+// 一次回调请求的记录
+type CallbackAttempt struct {
+	Time    string  `json:"time"`
+	Status  int     `json:"status,omitempty"` // 回调接口返回的HTTP状态码
+	Error   string  `json:"error,omitempty"`
+	Elapsed float64 `json:"elapsed"` // 请求花费的秒数
+}
+
+// 回调的投递记录
+type CallbackLog struct {
+	Url      string            `json:"url"`
+	State    string            `json:"state"`
+	Attempts []CallbackAttempt `json:"attempts,omitempty"`
+}
+
+// 回调的内容
+func callbackBody(status *JobStatus) ([]byte, error) {
+	code, msg := http.StatusOK, "OK"
+	if status.Failed > 0 {
+		code, msg = http.StatusInternalServerError, "failed to index docs"
+	}
+	return json.Marshal(map[string]interface{}{
+		"code":       code,
+		"msg":        msg,
+		"job":        status.Id,
+		"state":      status.State,
+		"index":      status.Index,
+		"docs":       status.Docs,
+		"failed":     status.Failed,
+		"errors":     status.Errors,
+		"start-time": status.StartTime,
+		"end-time":   status.EndTime,
+		"elapsed":    status.Elapsed,
+	})
+}
+
+// 回调的签名，接收方用相同的密钥计算后比较
+func signCallback(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 任务结束后发送回调，失败后按指数退避重试
+func (job *Job) notify(status JobStatus) {
+	if job.callback == "" {
+		return
+	}
+
+	body, err := callbackBody(&status)
+	if err != nil {
+		log.Printf("[error] failed to build callback of job %s: %v\n", status.Id, err)
+		job.setCallbackState(CallbackFailed)
+		return
+	}
+
+	cbConf := &conf.ServiceConf.Callback
+	interval := time.Duration(cbConf.RetryInterval) * time.Second
+	for i := 0; ; i++ {
+		if job.deliver(body) {
+			job.setCallbackState(CallbackDelivered)
+			return
+		}
+		if i >= cbConf.MaxRetries {
+			log.Printf("[error] gave up sending callback of job %s to %s after %d attempts\n", status.Id, job.callback, i+1)
+			job.setCallbackState(CallbackFailed)
+			return
+		}
+		time.Sleep(interval)
+		if interval *= 2; interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
+
+// 发送一次回调，回调接口返回2xx才算成功
+func (job *Job) deliver(body []byte) bool {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		CallbackJobHeader:       job.status.Id,
+		CallbackTimestampHeader: ts,
+	}
+	if secret := conf.ServiceConf.Callback.Secret; secret != "" {
+		headers[CallbackSignatureHeader] = signCallback(secret, ts, body)
+	}
+
+	start := time.Now()
+	code, content, resp, err := wget.PostJson(job.callback, "POST", bytes.NewReader(body), headers)
+	attempt := CallbackAttempt{
+		Time:    start.In(conf.Loc).Format(jobTimeFmt),
+		Elapsed: time.Since(start).Seconds(),
+	}
+	if resp != nil {
+		attempt.Status = code
+	}
+	if err == nil && (code < 200 || code >= 300) {
+		if len(content) > maxErrorContent {
+			content = content[:maxErrorContent]
+		}
+		err = fmt.Errorf("status %d: %s", code, string(content))
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		log.Printf("[error] failed to send callback of job %s to %s: %v\n", job.status.Id, job.callback, err)
+	} else {
+		log.Printf("send callback of job %s to %s OK: %s\n", job.status.Id, job.callback, string(content))
+	}
+
+	job.lock.Lock()
+	job.status.Callback.Attempts = append(job.status.Callback.Attempts, attempt)
+	job.lock.Unlock()
+	return err == nil
+}
+
+func (job *Job) setCallbackState(state string) {
+	job.lock.Lock()
+	job.status.Callback.State = state
+	job.lock.Unlock()
+}
@@ -0,0 +1,83 @@
+package indexer
+
+import (
+	"go-search/conf"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func Test_signCallback(t *testing.T) {
+	sig := signCallback("key", "1571000000", []byte(`{"a":1}`))
+	if sig != "sha256=b6abd060edeed2d2d826349cd10c2c81ab4875b57532d6276a38ab8ddc468876" {
+		t.Errorf("unexpected signature %s", sig)
+	}
+}
+
+// 回调接口前failures次返回500，之后返回200，检查每次请求的签名
+type callbackServer struct {
+	lock     sync.Mutex
+	failures int
+	requests int
+	badSigs  int
+}
+
+func (s *callbackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests++
+	sig := signCallback(conf.ServiceConf.Callback.Secret, r.Header.Get(CallbackTimestampHeader), body)
+	if r.Header.Get(CallbackSignatureHeader) != sig || r.Header.Get(CallbackJobHeader) == "" {
+		s.badSigs++
+	}
+	if s.requests <= s.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write([]byte("OK"))
+}
+
+func Test_notify(t *testing.T) {
+	saved := conf.ServiceConf.Callback
+	defer func() {
+		conf.ServiceConf.Callback = saved
+	}()
+	conf.ServiceConf.Callback.Secret = "key"
+	conf.ServiceConf.Callback.MaxRetries = 3
+	conf.ServiceConf.Callback.RetryInterval = 0 // 测试时不等待
+
+	cases := []struct {
+		failures int
+		attempts int
+		state    string
+	}{
+		{0, 1, CallbackDelivered},
+		{2, 3, CallbackDelivered},
+		{10, 4, CallbackFailed}, // 重试3次后放弃
+	}
+	for _, c := range cases {
+		s := &callbackServer{failures: c.failures}
+		server := httptest.NewServer(s)
+		job := newJob("test", server.URL)
+		job.notify(job.finish())
+		server.Close()
+
+		jobsLock.Lock()
+		delete(jobs, job.status.Id)
+		jobsLock.Unlock()
+
+		cb := job.status.Callback
+		if s.requests != c.attempts || len(cb.Attempts) != c.attempts || cb.State != c.state {
+			t.Errorf("failures %d: expected %d attempts and state %s, got %d requests, %+v", c.failures, c.attempts, c.state, s.requests, cb)
+		}
+		if s.badSigs > 0 {
+			t.Errorf("failures %d: %d callbacks with bad signature", c.failures, s.badSigs)
+		}
+		if c.failures > 0 && cb.Attempts[0].Status != http.StatusInternalServerError {
+			t.Errorf("failures %d: status of failed attempt should be recorded, got %+v", c.failures, cb.Attempts[0])
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package indexer
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// 统计满足q/fq/f条件的doc数，不排序、不输出doc
+func Count(index string, args *QueryArgs) (int, error) {
+	if !running {
+		return 0, fmt.Errorf("the service is stopped")
+	}
+
+	pq, err := parseQuery(args)
+	if err != nil {
+		return 0, err
+	}
+
+	idx, err := initIndexer(index)
+	if err != nil {
+		return 0, err
+	}
+
+	sr, err := idx.pq2SearchQuery(pq)
+	if err != nil {
+		return 0, err
+	}
+
+	// 没有需要打分时判断的条件，只用索引计数
+	if pq.filters == nil && pq.expr == nil {
+		sr.CountDocsOnly = true
+		return idx.engine.Search(*sr).NumDocs, nil
+	}
+
+	var count int64
+	sr.RankOpts.ScoringCriteria.(*scorerT).count = &count
+	idx.engine.Search(*sr)
+	return int(atomic.LoadInt64(&count)), nil
+}
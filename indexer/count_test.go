@@ -0,0 +1,43 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func Test_Count(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	cases := []struct {
+		args     QueryArgs
+		expected int
+	}{
+		// 没有需要打分时判断的条件，只用索引计数
+		{QueryArgs{}, 4},
+		{QueryArgs{Q: "shoes"}, 2},
+		{QueryArgs{Q: "+blue -used"}, 1},
+		{QueryArgs{Q: `"blue shoes"`}, 1},
+		{QueryArgs{F: "price:100~"}, 3},
+		{QueryArgs{Q: "shoes", F: "brand:acme"}, 2},
+		{QueryArgs{Fq: "name:hat"}, 1},
+		{QueryArgs{Q: "nothing"}, 0},
+		// 计数不受分页影响
+		{QueryArgs{Q: "shoes", PageSize: "1", Page: "2"}, 2},
+	}
+	for i, c := range cases {
+		n, err := Count(index, &c.args)
+		if err != nil {
+			t.Fatalf("case #%d: %v", i, err)
+		}
+		if n != c.expected {
+			t.Errorf("case #%d %+v: expected %d, got %d", i, c.args, c.expected, n)
+		}
+	}
+
+	if _, err := Count("no-such-index", &QueryArgs{}); err == nil {
+		t.Errorf("unknown index should be rejected")
+	}
+	if _, err := Count(index, &QueryArgs{Q: "(red"}); !IsBadRequest(err) {
+		t.Errorf("bad q should be a bad request, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package indexer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// search_after游标，记录上一页最后一个doc的排序字段值和docID
+type searchCursor struct {
+	values []sortValue // 为nil时从第一个结果开始
+	docId  string
+}
+
+// 游标的序列化格式，数值用十进制字符串保存，避免丢失精度
+type cursorJSON struct {
+	Sort   string    `json:"s"` // 排序条件，换了排序条件的游标不能使用
+	Values []*string `json:"v"`
+	DocId  string    `json:"id"`
+}
+
+func sortSignature(sortBys []sorting) string {
+	ss := make([]string, len(sortBys))
+	for i, sortBy := range sortBys {
+		ss[i] = fmt.Sprintf("%s:%v:%s", sortBy.fieldName, sortBy.asc, sortBy.collation)
+	}
+	return strings.Join(ss, ",")
+}
+
+func encodeCursor(values []sortValue, docId string, sortBys []sorting, schema *indexSchema) string {
+	c := cursorJSON{Sort: sortSignature(sortBys), Values: make([]*string, len(values)), DocId: docId}
+	for i, v := range values {
+		if !v.ok {
+			continue
+		}
+		s := v.s
+		if !isStringField(schema, sortBys[i].fIdx) {
+			s = strconv.FormatUint(v.u, 10)
+		}
+		c.Values[i] = &s
+	}
+	b, _ := json.Marshal(&c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(after string, sortBys []sorting, schema *indexSchema) (*searchCursor, error) {
+	if after == "" {
+		return &searchCursor{}, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(after)
+	if err != nil {
+		return nil, fmt.Errorf("bad cursor")
+	}
+	var c cursorJSON
+	if err = json.Unmarshal(b, &c); err != nil || len(c.Values) != len(sortBys) {
+		return nil, fmt.Errorf("bad cursor")
+	}
+	if c.Sort != sortSignature(sortBys) {
+		return nil, fmt.Errorf("cursor does not match the sorting")
+	}
+
+	values := make([]sortValue, len(sortBys))
+	for i, s := range c.Values {
+		if s == nil {
+			continue
+		}
+		values[i].ok = true
+		if isStringField(schema, sortBys[i].fIdx) {
+			values[i].s = *s
+		} else if values[i].u, err = strconv.ParseUint(*s, 10, 64); err != nil {
+			return nil, fmt.Errorf("bad cursor")
+		}
+	}
+	return &searchCursor{values: values, docId: c.DocId}, nil
+}
+
+// doc是否排在游标之后
+func (c *searchCursor) before(doc StoredDoc, docId string, sortBys []sorting, schema *indexSchema) bool {
+	if c.values == nil {
+		return true
+	}
+	return compareSortValues(c.values, docSortValues(doc, sortBys, schema), c.docId, docId, sortBys) < 0
+}
@@ -0,0 +1,149 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func Test_cursor(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, nil)
+	defer teardown()
+	idx, err := initIndexer(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := idx.schema
+	sortBys, err := parseS("brand:asc,price,ts:asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSortings(&sortBys, schema.FieldMap)
+
+	doc := StoredDoc{"brand": "acme", "price": float32(1.5)}
+	values := docSortValues(doc, sortBys, schema)
+	after := encodeCursor(values, "3", sortBys, schema)
+
+	c, err := decodeCursor(after, sortBys, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.docId != "3" || len(c.values) != 3 || c.values[0].s != "acme" || c.values[1].u != values[1].u || c.values[2].ok {
+		t.Errorf("unexpected cursor %+v", c)
+	}
+	// 排在游标之后的doc
+	if c.before(doc, "3", sortBys, schema) {
+		t.Errorf("the cursor doc itself should not be after the cursor")
+	}
+	if !c.before(StoredDoc{"brand": "acme", "price": float32(1)}, "1", sortBys, schema) {
+		t.Errorf("doc with lower price should be after the cursor")
+	}
+	if c.before(StoredDoc{"brand": "acme", "price": float32(2)}, "9", sortBys, schema) {
+		t.Errorf("doc with higher price should be before the cursor")
+	}
+	if !c.before(StoredDoc{"price": float32(1)}, "1", sortBys, schema) {
+		t.Errorf("doc without brand should be after the cursor")
+	}
+
+	if c, err = decodeCursor("", sortBys, schema); err != nil || c.values != nil {
+		t.Errorf("empty cursor should start from the first doc")
+	}
+	for _, bad := range []string{"!!!", "e30", after[:len(after)-4]} {
+		if _, err = decodeCursor(bad, sortBys, schema); err == nil {
+			t.Errorf("bad cursor %s should be rejected", bad)
+		}
+	}
+	otherSortBys, _ := parseS("price")
+	checkSortings(&otherSortBys, schema.FieldMap)
+	if _, err = decodeCursor(after, otherSortBys, schema); err == nil {
+		t.Errorf("cursor of another sorting should be rejected")
+	}
+}
+
+// 执行查询，返回当前页的docID、下一页的游标及total
+func queryPage(t *testing.T, index string, args *QueryArgs) ([]string, string, int) {
+	res, err := Query(index, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(res.Pagination)
+	var p struct {
+		Total int    `json:"total"`
+		After string `json:"after"`
+	}
+	_ = json.Unmarshal(b, &p)
+	var ids []string
+	if res.Docs != nil {
+		for doc := range res.Docs {
+			ids = append(ids, fmt.Sprintf("%v", doc.(StoredDoc)["id"]))
+		}
+	}
+	return ids, p.After, p.Total
+}
+
+func Test_Query_after(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, jsonDocs(`[
+		{"id": 1, "name": "a", "brand": "x", "price": 10},
+		{"id": 2, "name": "b", "brand": "y", "price": 10},
+		{"id": 3, "name": "c", "brand": "x", "price": 10},
+		{"id": 4, "name": "d", "brand": "y", "price": 20},
+		{"id": 5, "name": "e", "brand": "x", "price": 20},
+		{"id": 6, "name": "f", "brand": "y", "price": 30},
+		{"id": 7, "name": "g", "brand": "x"}
+	]`))
+	defer teardown()
+
+	// 数值排序由搜索引擎排序，字符串排序取出所有结果后排序
+	for _, s := range []string{"price:asc", "price", "brand:asc,price:asc"} {
+		seen := map[string]bool{}
+		var order []string
+		after := ""
+		for page := 0; ; page++ {
+			if page > 10 {
+				t.Fatalf("s=%s: too many pages", s)
+			}
+			ids, next, total := queryPage(t, index, &QueryArgs{S: s, PageSize: "2", After: after, HasAfter: true})
+			if total != 7 {
+				t.Errorf("s=%s page %d: total should count all matched docs, got %d", s, page, total)
+			}
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("s=%s: doc %s output twice", s, id)
+				}
+				seen[id] = true
+			}
+			order = append(order, ids...)
+			if next == "" {
+				break
+			}
+			after = next
+		}
+		if len(seen) != 7 {
+			t.Errorf("s=%s: expected all 7 docs, got %v", s, order)
+		}
+		// 没有排序字段值的doc排在最后
+		if order[len(order)-1] != "7" && s != "brand:asc,price:asc" {
+			t.Errorf("s=%s: doc without price should be the last, got %v", s, order)
+		}
+	}
+
+	// facet统计所有匹配的doc，不受游标影响
+	_, after, _ := queryPage(t, index, &QueryArgs{S: "price:asc", PageSize: "3", HasAfter: true})
+	res, err := Query(index, &QueryArgs{S: "price:asc", PageSize: "3", After: after, HasAfter: true, Facet: "brand"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range res.Docs {
+	}
+	count := 0
+	for _, fv := range res.Facets["brand"] {
+		count += fv.Count
+	}
+	if count != 7 {
+		t.Errorf("facets should count all 7 matched docs, got %v", res.Facets)
+	}
+
+	if _, err = Query(index, &QueryArgs{S: "price", After: after, HasAfter: true}); !IsBadRequest(err) {
+		t.Errorf("cursor of another sorting should be a bad request, got %v", err)
+	}
+}
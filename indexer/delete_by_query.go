@@ -0,0 +1,93 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// 按条件删除、修改全部doc时需要明确指定的q
+const MatchAll = "*"
+
+// 按条件删除、修改时没有任何条件
+var ErrEmptyQuery error = badRequestError{fmt.Errorf("q, fq or f must be specified, use q=* to match all docs")}
+
+// 打分时收集所有匹配的doc，打分函数会在多个shard中并发调用
+type matchedDocs struct {
+	lock   sync.Mutex
+	docIds []string
+	docs   []StoredDoc
+}
+
+func (m *matchedDocs) add(docId string, doc StoredDoc) {
+	m.lock.Lock()
+	m.docIds = append(m.docIds, docId)
+	m.docs = append(m.docs, doc)
+	m.lock.Unlock()
+}
+
+// 取出所有满足q/fq/f条件的doc
+func (idx *indexer) matchDocs(args *QueryArgs) (*matchedDocs, error) {
+	pq, err := parseQuery(args)
+	if err != nil {
+		return nil, err
+	}
+
+	sr, err := idx.pq2SearchQuery(pq)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := &matchedDocs{}
+	sr.RankOpts.ScoringCriteria.(*scorerT).matched = matched
+	idx.engine.Search(*sr)
+	return matched, nil
+}
+
+// 没有任何条件时返回ErrEmptyQuery，避免误删、误改整个索引库；q为MatchAll时去掉q，匹配所有doc
+func checkQueryConds(args *QueryArgs) (*QueryArgs, error) {
+	a := *args
+	if strings.TrimSpace(a.Q) == MatchAll {
+		a.Q = ""
+		return &a, nil
+	}
+	if strings.TrimSpace(a.Q) == "" && strings.TrimSpace(a.Fq) == "" && strings.TrimSpace(a.F) == "" {
+		return nil, ErrEmptyQuery
+	}
+	return &a, nil
+}
+
+// 删除所有满足q/fq/f条件的doc，返回删除的doc数。dryRun时只计数，不删除
+// 删除全部doc需要指定q=*
+func DeleteByQuery(index string, args *QueryArgs, dryRun bool) (int, error) {
+	args, err := checkQueryConds(args)
+	if err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return Count(index, args)
+	}
+
+	if !running {
+		return 0, fmt.Errorf("the service is stopped")
+	}
+
+	idx, err := initIndexer(index)
+	if err != nil {
+		return 0, err
+	}
+
+	// 查找和删除期间不能有其它更新，删除生效后才能解锁
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+
+	// 先取出所有docID再删除，不在搜索过程中修改索引
+	matched, err := idx.matchDocs(args)
+	if err != nil {
+		return 0, err
+	}
+	idx.deleteDocsSync(matched.docIds)
+	log.Printf("[info] %d docs deleted from index %s\n", len(matched.docIds), idx.schema.Name)
+	return len(matched.docIds), nil
+}
@@ -0,0 +1,50 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func Test_DeleteByQuery(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	// 没有条件时不删除
+	for _, args := range []*QueryArgs{{}, {Q: " "}} {
+		if _, err := DeleteByQuery(index, args, false); err != ErrEmptyQuery {
+			t.Errorf("empty query should be rejected, got %v", err)
+		}
+		if _, err := DeleteByQuery(index, args, true); err != ErrEmptyQuery {
+			t.Errorf("empty query should be rejected in dry run, got %v", err)
+		}
+	}
+	if n, _ := Count(index, &QueryArgs{}); n != 4 {
+		t.Fatalf("no doc should be deleted, %d docs left", n)
+	}
+
+	n, err := DeleteByQuery(index, &QueryArgs{Q: "shoes"}, true)
+	if err != nil || n != 2 {
+		t.Errorf("dry run should count 2 docs, got %d, %v", n, err)
+	}
+	if n, _ = Count(index, &QueryArgs{}); n != 4 {
+		t.Errorf("dry run should not delete docs, %d docs left", n)
+	}
+
+	if n, err = DeleteByQuery(index, &QueryArgs{Q: "shoes", F: "price:100~"}, false); err != nil || n != 1 {
+		t.Errorf("expected 1 doc deleted, got %d, %v", n, err)
+	}
+	docs, _ := GetDocs(index, []string{"1", "2"}, "")
+	if _, ok := docs["2"]; ok || docs["1"] == nil {
+		t.Errorf("only doc 2 should be deleted, got %v", docs)
+	}
+
+	// q=*匹配所有doc，可以再用f过滤
+	if n, err = DeleteByQuery(index, &QueryArgs{Q: MatchAll, F: "brand:foo"}, false); err != nil || n != 1 {
+		t.Errorf("expected 1 doc deleted, got %d, %v", n, err)
+	}
+	if n, err = DeleteByQuery(index, &QueryArgs{Q: MatchAll}, false); err != nil || n != 2 {
+		t.Errorf("expected 2 docs deleted, got %d, %v", n, err)
+	}
+	if n, _ = Count(index, &QueryArgs{}); n != 0 {
+		t.Errorf("all docs should be deleted, %d docs left", n)
+	}
+}
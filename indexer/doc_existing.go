@@ -3,82 +3,160 @@ package indexer
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/go-ego/riot/types"
 )
 
-// 根据已有字段，组装成docid的过滤条件
-func (idx *indexer) makeDocIDFilters(doc map[string]interface{}) (filters string, err error) {
-	pkIdx := idx.schema.PKIdx
+// 索引库不存在
+var ErrIndexNotFound = fmt.Errorf("index not found")
+
+// docID -> 保存的doc，opThread写入搜索引擎后同步修改，用于按docID直接获取doc
+// 保存的doc不会被修改，写入时总是替换为新的doc
+type docMap struct {
+	lock sync.RWMutex
+	docs map[string]StoredDoc
+}
+
+func newDocMap() *docMap {
+	return &docMap{docs: map[string]StoredDoc{}}
+}
+
+func (m *docMap) set(docId string, doc StoredDoc) {
+	m.lock.Lock()
+	m.docs[docId] = doc
+	m.lock.Unlock()
+}
+
+func (m *docMap) remove(docId string) {
+	m.lock.Lock()
+	delete(m.docs, docId)
+	m.lock.Unlock()
+}
+
+func (m *docMap) get(docIds []string) map[string]StoredDoc {
+	res := make(map[string]StoredDoc, len(docIds))
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, docId := range docIds {
+		if doc, ok := m.docs[docId]; ok {
+			res[docId] = doc
+		}
+	}
+	return res
+}
+
+// 使用存储时，搜索引擎初始化时会恢复所有doc，遍历一次生成docMap
+func (idx *indexer) loadDocMap() {
+	idx.engine.Search(types.SearchReq{
+		Labels: allDocs,
+		Tokens: allDocs,
+		RankOpts: &types.RankOpts{
+			ScoringCriteria: &docMapLoader{docs: idx.docs},
+		},
+	})
+}
+
+// 遍历所有doc生成docMap，必须实现types.ScoringCriteria
+type docMapLoader struct {
+	docs *docMap
+}
+
+func (loader *docMapLoader) Score(doc types.IndexedDoc, fields interface{}) []float32 {
+	if storedDoc, ok := fields.(StoredDoc); ok {
+		loader.docs.set(doc.DocId, storedDoc)
+	}
+	return []float32{}
+}
+
+// 根据PK字段的值生成docID，多个PK字段的值用'_'连接
+func (idx *indexer) makeDocID(pk map[int]interface{}) string {
+	docID := strings.Builder{}
+	for i, fIdx := range idx.schema.PKIdx {
+		if i > 0 {
+			docID.WriteByte('_')
+		}
+		docID.WriteString(fmt.Sprintf("%v", pk[fIdx]))
+	}
+	return docID.String()
+}
+
+// 根据doc中已有的PK字段，生成docID
+func (idx *indexer) docIDOf(doc map[string]interface{}) (string, error) {
 	fm := idx.schema.FieldMap
 	fields := idx.schema.Fields
 
-	pkCount := len(pkIdx)
-	pk := make([]string, pkCount)
-	c := 0
-
+	pk := map[int]interface{}{}
 	for fieldName, value := range doc {
 		fieldIdx, ok := fm[fieldName]
 		if !ok {
 			continue
 		}
 		field := &fields[fieldIdx]
-
-		if field.PK {
-			pk[c] = fmt.Sprintf("%s:%v", fieldName, value)
-			c++
-			if c >= pkCount {
-				break
-			}
+		if !field.PK {
+			continue
 		}
+		val, err := field.ToNativeValue(value)
+		if err != nil {
+			return "", badRequestError{err}
+		}
+		pk[fieldIdx] = val
 	}
-	if c < pkCount {
-		return "", fmt.Errorf("pk number not matched")
+	if len(pk) != len(idx.schema.PKIdx) {
+		return "", badRequestf("pk number not matched")
 	}
-	return strings.Join(pk, ";"), nil
+	return idx.makeDocID(pk), nil
+}
+
+// 根据docID直接取出保存的doc，不存在的docID不在结果中
+func (idx *indexer) lookupDocs(docIds []string) map[string]StoredDoc {
+	return idx.docs.get(docIds)
 }
 
 func (idx *indexer) getDoc(doc map[string]interface{}) (map[string]interface{}, error) {
-	filters, err := idx.makeDocIDFilters(doc)
+	docId, err := idx.docIDOf(doc)
 	if err != nil {
 		return nil, err
 	}
 
-	pq, err := parseQuery("", "", "", filters, "1", "1", "")
-	if err != nil {
-		return nil, err
+	storedDoc, ok := idx.lookupDocs([]string{docId})[docId]
+	if !ok {
+		return nil, nil
 	}
-	sr, err := idx.pq2SearchQuery(pq)
-	if err != nil {
-		return nil, err
+	return idx.editableDoc(storedDoc), nil
+}
+
+// 复制保存的doc用于修改后重新索引，时间字段格式化为字符串
+func (idx *indexer) editableDoc(storedDoc StoredDoc) map[string]interface{} {
+	// 返回的doc会被修改，不能直接使用保存的doc
+	retDoc := make(map[string]interface{}, len(storedDoc))
+	for k, v := range idx.outputDoc(storedDoc, nil) {
+		retDoc[k] = v
 	}
-	searchResp := idx.engine.Search(*sr)
+	return retDoc
+}
 
-	if searchResp.Docs == nil {
-		return nil, nil
+// 根据docID获取doc，只输出fl中的字段，不存在的docID不在结果中
+func GetDocs(index string, docIds []string, fl string) (map[string]StoredDoc, error) {
+	if !running {
+		return nil, fmt.Errorf("the service is stopped")
 	}
-	docs, ok := searchResp.Docs.(types.ScoredDocs)
-	if !ok || len(docs) == 0 {
-		return nil, nil
+
+	idx, err := initIndexer(index)
+	if err != nil {
+		return nil, ErrIndexNotFound
 	}
 
-	schema := idx.schema
-	var retDoc StoredDoc
-	for _, doc := range docs {
-		storedDoc, ok := doc.Fields.(StoredDoc)
-		if !ok {
-			continue
-		}
-		retDoc = StoredDoc{}
-		for k, v := range storedDoc {
-			if fIdx, ok := schema.TimeIdx[k]; !ok {
-				retDoc[k] = v
-			} else {
-				field := &schema.Fields[fIdx]
-				retDoc[k] = field.FormatDatetime(v)
-			}
+	outFieldList := parseFl(fl)
+	for _, fn := range outFieldList {
+		if _, ok := idx.schema.FieldMap[fn]; !ok {
+			return nil, fmt.Errorf("out field %s not found", fn)
 		}
-		return retDoc, nil
 	}
-	return nil, nil
+
+	docs := idx.lookupDocs(docIds)
+	for docId, storedDoc := range docs {
+		docs[docId] = idx.outputDoc(storedDoc, outFieldList)
+	}
+	return docs, nil
 }
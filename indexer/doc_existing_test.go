@@ -0,0 +1,40 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func Test_GetDocs(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	docs, err := GetDocs(index, []string{"1", "3", "5"}, "id,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 || docs["1"]["name"] != "red shoes" || docs["3"]["name"] != "blue shirt" {
+		t.Errorf("unexpected docs %v", docs)
+	}
+	if _, ok := docs["1"]["brand"]; ok {
+		t.Errorf("field brand should not be output")
+	}
+
+	// 写入、删除后立即可以获取
+	if _, _, err = IndexDoc(index, jsonDocs(`[{"id": 5, "name": "new"}]`)[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteDoc(index, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	docs, _ = GetDocs(index, []string{"1", "5"}, "")
+	if _, ok := docs["1"]; ok || docs["5"]["name"] != "new" {
+		t.Errorf("unexpected docs %v", docs)
+	}
+
+	if _, err = GetDocs(index, []string{"1"}, "unknown"); err == nil {
+		t.Errorf("unknown out field should be rejected")
+	}
+	if _, err = GetDocs("no-such-index", []string{"1"}, ""); err != ErrIndexNotFound {
+		t.Errorf("expected ErrIndexNotFound, got %v", err)
+	}
+}
@@ -63,7 +63,12 @@ func fromCsvFile(in io.Reader) (<-chan Doc, error) {
 					break
 				}
 				docChan <- Doc{nil, err}
-				continue
+				if _, ok := err.(*csv.ParseError); ok {
+					continue
+				}
+				// 读文件出错，如导入任务取消时文件被关闭
+				close(docChan)
+				break
 			}
 
 			doc := make(map[string]interface{}, l)
@@ -86,6 +91,8 @@ func fromJSONLines(in io.Reader) (<-chan Doc, error) {
 		for dec.More() {
 			var doc map[string]interface{}
 			if err := dec.Decode(&doc); err != nil {
+				// 出错后无法继续解析后面的行
+				docChan <- Doc{nil, err}
 				break
 			}
 			docChan <- Doc{doc, nil}
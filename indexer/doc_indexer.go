@@ -2,85 +2,138 @@ package indexer
 
 import (
 	"fmt"
-	"go-search/conf"
 	"io"
 	"log"
-	"net/http"
 	"os"
 	"strings"
 
 	"github.com/go-ego/riot/types"
-	"github.com/rosbit/go-wget"
 )
 
 // IndexJSON/IndexCSV/... 等从文件获取doc建索引的函数签名
-type FnIndexReader func(string, io.ReadCloser, ...string) ([]string, error)
+// 有cb参数时异步导入，cb[0]为回调URL(可以为空)，cb[1]为临时文件名，返回任务ID
+type FnIndexReader func(string, io.ReadCloser, ...string) (docIds []string, jobId string, err error)
 
-// IndexDoc/UpdateDoc: 更新一个doc
-type FnUpdateDoc func(index string, doc map[string]interface{}) (docId string, err error)
+// 要修改的doc不存在
+var ErrDocNotFound = fmt.Errorf("doc not found")
+
+// 服务内部的错误，不是请求内容引起的
+type internalError struct {
+	error
+}
+
+// 请求中的参数或doc不合法，如字段值类型不对、缺少PK字段、操作符错误
+type badRequestError struct {
+	error
+}
+
+func badRequestf(format string, a ...interface{}) error {
+	return badRequestError{fmt.Errorf(format, a...)}
+}
+
+// 是否是请求中的参数或doc不合法引起的错误
+func IsBadRequest(err error) bool {
+	_, ok := err.(badRequestError)
+	return ok
+}
+
+// IndexDoc/UpdateDoc: 更新一个doc，ifVersion不为nil时doc的当前版本必须与之相同，否则返回ErrVersionConflict
+type FnUpdateDoc func(index string, doc map[string]interface{}, ifVersion *uint64) (docId string, version uint64, err error)
 
 // 把一个doc添加到索引库
-func IndexDoc(index string, doc map[string]interface{}) (docID string, err error) {
+func IndexDoc(index string, doc map[string]interface{}, ifVersion *uint64) (docID string, version uint64, err error) {
 	if !running {
-		return "", fmt.Errorf("the service is stopped")
+		return "", 0, fmt.Errorf("the service is stopped")
 	}
 
 	idx, err := initIndexer(index)
 	if err != nil {
-		return "", fmt.Errorf("schema %s not found, please create schema first", index)
+		return "", 0, fmt.Errorf("schema %s not found, please create schema first", index)
 	}
 
-	docID, err = idx.indexDoc(doc)
-	if err != nil {
-		return "", err
+	// 检查版本和写入期间不能有其它更新，写入的doc生效后才能解锁，否则其它更新会读到旧的doc
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+
+	if ifVersion != nil {
+		existingDoc, err := idx.getDoc(doc)
+		if err != nil {
+			return "", 0, err
+		}
+		if err = checkVersion(existingDoc, ifVersion); err != nil {
+			return "", 0, err
+		}
 	}
-	idx.flush()
-	return docID, nil
+	return idx.indexDocSync(doc)
+}
+
+// 更新一个doc，可以只更新出现的字段，也可以使用$set/$inc/$unset/$append/$remove操作符。如果doc不存在，更新会失败
+func UpdateDoc(index string, doc map[string]interface{}, ifVersion *uint64) (docID string, version uint64, err error) {
+	return updateDoc(index, doc, ifVersion, false)
+}
+
+// 同UpdateDoc，doc不存在时用PK字段和更新的字段创建doc
+func UpsertDoc(index string, doc map[string]interface{}, ifVersion *uint64) (docID string, version uint64, err error) {
+	return updateDoc(index, doc, ifVersion, true)
 }
 
-// 更新一个doc，可以只更新出现的字段。如果doc不存在，更新会失败
-func UpdateDoc(index string, doc map[string]interface{}) (docID string, err error) {
+func updateDoc(index string, doc map[string]interface{}, ifVersion *uint64, upsert bool) (docID string, version uint64, err error) {
 	if !running {
-		return "", fmt.Errorf("the service is stopped")
+		return "", 0, fmt.Errorf("the service is stopped")
 	}
 
 	idx, err := initIndexer(index)
 	if err != nil {
-		return "", fmt.Errorf("schema %s not found, please create schema first", index)
+		return "", 0, fmt.Errorf("schema %s not found, please create schema first", index)
 	}
 
-	existingDoc, err := idx.getDoc(doc)
+	doc, updates, err := parseUpdateOps(doc)
 	if err != nil {
-		return "", err
+		return "", 0, err
+	}
+	if err = updates.check(idx.schema); err != nil {
+		return "", 0, err
 	}
-	fmt.Printf("exsiting doc: %v\n", existingDoc)
-	fmt.Printf("updating doc: %v\n", doc)
 
+	// 读出、修改、写回期间不能有其它更新，写回的doc生效后才能解锁
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+
+	existingDoc, err := idx.getDoc(doc)
+	if err != nil {
+		return "", 0, err
+	}
+	if err = checkVersion(existingDoc, ifVersion); err != nil {
+		return "", 0, err
+	}
+	if existingDoc == nil {
+		if !upsert {
+			return "", 0, ErrDocNotFound
+		}
+		existingDoc = map[string]interface{}{}
+	}
 	for k, v := range doc {
 		existingDoc[k] = v
 	}
-	fmt.Printf("new doc: %v\n", existingDoc)
-
-	docID, err = idx.indexDoc(existingDoc)
-	if err != nil {
-		return "", err
+	if err = updates.apply(existingDoc, idx.schema); err != nil {
+		return "", 0, err
 	}
-	idx.flush()
-	return docID, nil
+
+	return idx.indexDocSync(existingDoc)
 }
 
 // 把多个JSON(JSON数组)添加到索引库
-func IndexJSON(index string, in io.ReadCloser, cb ...string) (docIds []string, err error) {
+func IndexJSON(index string, in io.ReadCloser, cb ...string) (docIds []string, jobId string, err error) {
 	return indexFromDocGenerator(index, in, fromJSONFile, cb...)
 }
 
 // 把csv中的一行作为doc添加到索引库
-func IndexCSV(index string, in io.ReadCloser, cb ...string) (docIds []string, err error) {
+func IndexCSV(index string, in io.ReadCloser, cb ...string) (docIds []string, jobId string, err error) {
 	return indexFromDocGenerator(index, in, fromCsvFile, cb...)
 }
 
 // 把JSON Lines(每行一个JSON)添加到索引库
-func IndexJSONLines(index string, in io.ReadCloser, cb ...string) (docIds []string, err error) {
+func IndexJSONLines(index string, in io.ReadCloser, cb ...string) (docIds []string, jobId string, err error) {
 	return indexFromDocGenerator(index, in, fromJSONLines, cb...)
 }
 
@@ -89,9 +142,10 @@ func indexFromDocGenerator(
 	index string,
 	in io.ReadCloser,
 	docGenerator fnReaderGenerator, cb ...string,
-) (docIds []string, err error) {
+) (docIds []string, jobId string, err error) {
 	var idx *indexer
 	var docChan <-chan Doc
+	var job *Job
 
 	if !running {
 		err = fmt.Errorf("the service is stopped")
@@ -111,16 +165,21 @@ func indexFromDocGenerator(
 	if len(cb) == 0 {
 		defer in.Close()
 		// no callback
-		return idx.indexDocs(docChan), nil
+		return idx.indexDocs(docChan, nil), "", nil
 	}
 
 	// with callback
+	job = newJob(index, cb[0])
 	go func() {
 		defer os.Remove(cb[1])
-		defer in.Close()
-		idx.indexDocs(docChan, cb...)
+		idx.indexDocs(docChan, job)
+		in.Close()
+		// 取消时读完剩下的doc，生成doc的goroutine才能结束
+		for range docChan {
+		}
+		job.notify(job.finish())
 	}()
-	return nil, nil
+	return nil, job.status.Id, nil
 
 ERROR:
 	in.Close()
@@ -130,8 +189,8 @@ ERROR:
 	return
 }
 
-// 删除一个doc
-func DeleteDoc(index string, docID interface{}) error {
+// 删除一个doc，ifVersion不为nil时doc的当前版本必须与之相同，否则返回ErrVersionConflict
+func DeleteDoc(index string, docID interface{}, ifVersion *uint64) error {
 	if !running {
 		return fmt.Errorf("the service is stopped")
 	}
@@ -139,8 +198,20 @@ func DeleteDoc(index string, docID interface{}) error {
 	if err != nil {
 		return fmt.Errorf("schema %s not found, please create schema first", index)
 	}
-	idx.deleteDoc(fmt.Sprintf("%v", docID))
-	idx.flush()
+
+	dID := fmt.Sprintf("%v", docID)
+
+	// 删除生效后才能解锁，否则其它更新会读到被删除的doc并写回
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+
+	if ifVersion != nil {
+		existingDoc := idx.lookupDocs([]string{dID})[dID]
+		if err = checkVersion(existingDoc, ifVersion); err != nil {
+			return err
+		}
+	}
+	idx.deleteDocsSync([]string{dID})
 	return nil
 }
 
@@ -154,15 +225,25 @@ func DeleteDocs(index string, docIds []interface{}) error {
 	if err != nil {
 		return fmt.Errorf("schema %s not found, please create schema first", index)
 	}
-	for _, docID := range docIds {
-		idx.deleteDoc(fmt.Sprintf("%v", docID))
+	ids := make([]string, len(docIds))
+	for i, docID := range docIds {
+		ids[i] = fmt.Sprintf("%v", docID)
 	}
-	idx.flush()
+
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+	idx.deleteDocsSync(ids)
 	return nil
 }
 
 //索引中增加一个文档
 func (idx *indexer) indexDoc(doc map[string]interface{}) (string, error) {
+	docID, _, err := idx.sendIndexOp(doc, nil)
+	return docID, err
+}
+
+// 生成增加文档的操作交给opThread，返回docID和新的版本号，done不为nil时操作完成后关闭
+func (idx *indexer) sendIndexOp(doc map[string]interface{}, done chan struct{}) (string, uint64, error) {
 	storedDoc := StoredDoc{}
 	tokens := []types.TokenData{}
 
@@ -180,7 +261,7 @@ func (idx *indexer) indexDoc(doc map[string]interface{}) (string, error) {
 
 		val, err := field.ToNativeValue(value)
 		if err != nil {
-			return "", err
+			return "", 0, badRequestError{err}
 		}
 		if field.PK {
 			pk[fieldIdx] = val
@@ -190,17 +271,13 @@ func (idx *indexer) indexDoc(doc map[string]interface{}) (string, error) {
 		case string:
 			s := i
 			var segTokens []string
-			switch field.Tokenizer {
-			case conf.ZhTokenizer:
-				// segTokens = engine.Segment(s)
-				segTokens = hanziTokenize(s)
-			case conf.NoneTokenizer:
-				// segTokens = []string{strings.TrimSpace(s)}
+			if analyzer := fieldAnalyzer(idx.schema, fieldIdx); analyzer != nil {
+				segTokens = analyzer.Analyze(s)
+			} else {
 				val = strings.TrimSpace(s)
-			default:
-				segTokens = whitespaceTokenize(s)
 			}
 			if len(segTokens) > 0 {
+				idx.dict.add(fieldIdx, segTokens)
 				fieldTokens := buildIndexTokens(fieldIdx, segTokens, startLoc)
 				tokens = append(tokens, fieldTokens...)
 				startLoc += len(fieldTokens) + 10 // 与下一字段的索引间加上几个间隔
@@ -211,18 +288,15 @@ func (idx *indexer) indexDoc(doc map[string]interface{}) (string, error) {
 	}
 	pkIdx := idx.schema.PKIdx
 	if len(pk) != len(pkIdx) {
-		return "", fmt.Errorf("pk field must be specified")
+		return "", 0, badRequestf("pk field must be specified")
 	}
-
-	docID := strings.Builder{}
-	for i, idx := range pkIdx {
-		if i > 0 {
-			docID.WriteByte('_')
-		}
-		docID.WriteString(fmt.Sprintf("%v", pk[idx]))
+	version, err := nextVersion()
+	if err != nil {
+		return "", 0, internalError{err}
 	}
+	storedDoc[VersionField] = version
 
-	dID := docID.String()
+	dID := idx.makeDocID(pk)
 	count := mergeTokenLocs(&tokens)
 	indexerChan <- &indexerOp{
 		op:     TypeIndexDoc,
@@ -233,36 +307,57 @@ func (idx *indexer) indexDoc(doc map[string]interface{}) (string, error) {
 			Fields: storedDoc,
 			Labels: allDocs,
 		},
+		docs: idx.docs,
+		done: done,
 	}
-	return dID, nil
+	return dID, version, nil
 }
 
-//批量增加索引文档
-func (idx *indexer) indexDocs(docs <-chan Doc, cb ...string) (docIds []string) {
-	hasError := false
-	hasCb := len(cb) > 0
+// 增加文档，返回时已经可以搜索到
+func (idx *indexer) indexDocSync(doc map[string]interface{}) (string, uint64, error) {
+	done := make(chan struct{})
+	docID, version, err := idx.sendIndexOp(doc, done)
+	if err != nil {
+		return "", 0, err
+	}
+	<-done
+	idx.flushSync()
+	return docID, version, nil
+}
 
+// 批量增加索引文档，job不为nil时是异步任务，结果记录在job中，任务取消时停止
+func (idx *indexer) indexDocs(docs <-chan Doc, job *Job) (docIds []string) {
 	count := 0
+	ordinal := 0 // 第几个doc
 	for doc := range docs {
+		ordinal++
+		if job != nil && job.isCanceled() {
+			log.Printf("[info] job %s canceled\n", job.status.Id)
+			break
+		}
+
 		if doc.err != nil {
-			if !hasCb {
+			if job == nil {
 				docIds = append(docIds, doc.err.Error())
 			} else {
 				log.Printf("[error] indexing %s: %v\n", idx.schema.Name, doc.err.Error())
+				job.addError(ordinal, doc.err)
 			}
 			continue
 		}
 
 		if docID, err := idx.indexDoc(doc.doc); err != nil {
-			if !hasCb {
+			if job == nil {
 				docIds = append(docIds, err.Error())
 			} else {
 				log.Printf("[error] indexing %s: %v\n", idx.schema.Name, err.Error())
+				job.addError(ordinal, err)
 			}
-			hasError = true
 		} else {
-			if !hasCb {
+			if job == nil {
 				docIds = append(docIds, docID)
+			} else {
+				job.addDoc()
 			}
 			count++
 		}
@@ -272,32 +367,6 @@ func (idx *indexer) indexDocs(docs <-chan Doc, cb ...string) (docIds []string) {
 		idx.flush()
 	}
 	log.Printf("[info] %d docs appended to index %s\n", count, idx.schema.Name)
-
-	if hasCb {
-		params := func() map[string]interface{} {
-			if hasError {
-				return map[string]interface{}{
-					"code":  http.StatusInternalServerError,
-					"msg":   "failed to index docs",
-					"index": idx.schema.Name,
-					"docs":  count,
-				}
-			}
-			return map[string]interface{}{
-				"code":  http.StatusOK,
-				"msg":   "OK",
-				"index": idx.schema.Name,
-				"docs":  count,
-			}
-		}
-
-		status, content, _, err := wget.PostJson(cb[0], "POST", params(), nil)
-		if err != nil {
-			log.Printf("failed to send callback to %s: %d\n", cb[0], status)
-		} else {
-			log.Printf("send to callback to %s OK: %s\n", cb[0], string(content))
-		}
-	}
 	return
 }
 
@@ -343,11 +412,30 @@ func mergeTokenLocs(pTokens *[]types.TokenData) int {
 	return count
 }
 
-func (idx *indexer) deleteDoc(docID string) {
+// 删除文档，返回时已经生效
+func (idx *indexer) deleteDocsSync(docIds []string) {
+	if len(docIds) == 0 {
+		return
+	}
+	dones := make([]chan struct{}, len(docIds))
+	for i, docID := range docIds {
+		dones[i] = make(chan struct{})
+		idx.sendDeleteOp(docID, dones[i])
+	}
+	for _, done := range dones {
+		<-done
+	}
+	idx.flushSync()
+}
+
+// 生成删除文档的操作交给opThread，done不为nil时操作完成后关闭
+func (idx *indexer) sendDeleteOp(docID string, done chan struct{}) {
 	indexerChan <- &indexerOp{
 		op:     TypeDeleteDoc,
 		engine: idx.engine,
 		docID:  docID,
+		docs:   idx.docs,
+		done:   done,
 	}
 }
 
@@ -357,3 +445,14 @@ func (idx *indexer) flush() {
 		engine: idx.engine,
 	}
 }
+
+// 等待之前的所有操作生效
+func (idx *indexer) flushSync() {
+	done := make(chan struct{})
+	indexerChan <- &indexerOp{
+		op:     TypeFlushDoc,
+		engine: idx.engine,
+		done:   done,
+	}
+	<-done
+}
@@ -0,0 +1,288 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// 对doc字段的修改，与$set/$inc/$unset/$append/$remove操作符一一对应，一个字段只能出现在一种修改中
+type fieldUpdates struct {
+	Set    map[string]interface{} // 设置字段值
+	Inc    map[string]interface{} // 数值字段加上给定的值，可以为负数
+	Unset  []string               // 删除字段
+	Append map[string]interface{} // 空格分词的字段追加标签，已有的标签不重复追加
+	Remove map[string]interface{} // 空格分词的字段删除标签
+}
+
+// 从更新的doc中分离出$set/$inc/$unset/$append/$remove操作符，返回其余的字段和操作符表示的修改
+func parseUpdateOps(doc map[string]interface{}) (map[string]interface{}, *fieldUpdates, error) {
+	fields := make(map[string]interface{}, len(doc))
+	updates := &fieldUpdates{}
+	for k, v := range doc {
+		if !strings.HasPrefix(k, "$") {
+			fields[k] = v
+			continue
+		}
+
+		if k == "$unset" {
+			// ["f1", "f2"]或{"f1": "", "f2": ""}
+			switch names := v.(type) {
+			case []interface{}:
+				for _, name := range names {
+					updates.Unset = append(updates.Unset, fmt.Sprintf("%v", name))
+				}
+			case map[string]interface{}:
+				for name := range names {
+					updates.Unset = append(updates.Unset, name)
+				}
+			default:
+				return nil, nil, badRequestf("value of $unset must be an array or an object")
+			}
+			continue
+		}
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil, badRequestf("value of %s must be an object", k)
+		}
+		switch k {
+		case "$set":
+			updates.Set = m
+		case "$inc":
+			updates.Inc = m
+		case "$append":
+			updates.Append = m
+		case "$remove":
+			updates.Remove = m
+		default:
+			return nil, nil, badRequestf("unknown operator %s", k)
+		}
+	}
+	return fields, updates, nil
+}
+
+func (u *fieldUpdates) empty() bool {
+	return len(u.Set) == 0 && len(u.Inc) == 0 && len(u.Unset) == 0 && len(u.Append) == 0 && len(u.Remove) == 0
+}
+
+// 检查修改的字段是否合法
+func (u *fieldUpdates) check(schema *indexSchema) error {
+	updated := map[string]bool{}
+	getField := func(fieldName string) (*conf.Field, error) {
+		fIdx, ok := schema.FieldMap[fieldName]
+		if !ok {
+			return nil, badRequestf("field %s not found", fieldName)
+		}
+		field := &schema.Fields[fIdx]
+		if field.PK {
+			return nil, badRequestf("pk field %s can not be updated", fieldName)
+		}
+		if updated[fieldName] {
+			return nil, badRequestf("field %s updated more than once", fieldName)
+		}
+		updated[fieldName] = true
+		return field, nil
+	}
+
+	for fieldName, v := range u.Set {
+		field, err := getField(fieldName)
+		if err != nil {
+			return err
+		}
+		if _, err = field.ToNativeValue(v); err != nil {
+			return badRequestf("bad value for field %s: %v", fieldName, err)
+		}
+	}
+	for fieldName, v := range u.Inc {
+		field, err := getField(fieldName)
+		if err != nil {
+			return err
+		}
+		if !field.IsNumber() {
+			return badRequestf("field %s to increase must be a number", fieldName)
+		}
+		d, ok := v.(float64)
+		if !ok {
+			return badRequestf("increment of field %s must be a number", fieldName)
+		}
+		if isIntField(field) && d != math.Trunc(d) {
+			return badRequestf("increment of field %s must be an integer", fieldName)
+		}
+	}
+	for _, tags := range []map[string]interface{}{u.Append, u.Remove} {
+		for fieldName := range tags {
+			field, err := getField(fieldName)
+			if err != nil {
+				return err
+			}
+			if (field.Type != conf.StringType && field.Type != conf.StringStrType) || field.AnalyzerName() != conf.WsTokenizer {
+				return badRequestf("tags field %s must be a string with tokenizer space", fieldName)
+			}
+		}
+	}
+	for _, fieldName := range u.Unset {
+		if _, err := getField(fieldName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 修改doc，doc是editableDoc()的结果
+func (u *fieldUpdates) apply(doc map[string]interface{}, schema *indexSchema) error {
+	for fieldName, v := range u.Set {
+		doc[fieldName] = v
+	}
+	for fieldName, v := range u.Inc {
+		field := &schema.Fields[schema.FieldMap[fieldName]]
+		res, err := incNumber(field, doc[fieldName], v.(float64))
+		if err != nil {
+			return err
+		}
+		doc[fieldName] = res
+	}
+	for fieldName, v := range u.Append {
+		tags, _ := doc[fieldName].(string)
+		doc[fieldName] = appendTags(tags, fmt.Sprintf("%v", v))
+	}
+	for fieldName, v := range u.Remove {
+		tags, _ := doc[fieldName].(string)
+		doc[fieldName] = removeTags(tags, fmt.Sprintf("%v", v))
+	}
+	for _, fieldName := range u.Unset {
+		delete(doc, fieldName)
+	}
+	return nil
+}
+
+func isIntField(field *conf.Field) bool {
+	return field.IsNumber() && !strings.HasPrefix(field.Type, "f")
+}
+
+// 数值字段的值加上d，字段没有值时作为0，整数不经过float64转换，避免丢失精度
+func incNumber(field *conf.Field, v interface{}, d float64) (interface{}, error) {
+	if v == nil {
+		var err error
+		if v, err = field.ToNativeValue(nil); err != nil {
+			return nil, err
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch v.(type) {
+	case int8, int16, int32, int64, int:
+		return rv.Int() + int64(d), nil
+	case uint8, uint16, uint32, uint64, uint:
+		u := rv.Uint()
+		if d < 0 && uint64(-d) > u {
+			return nil, badRequestf("value of field %s out of range", field.Name)
+		}
+		if d < 0 {
+			return u - uint64(-d), nil
+		}
+		return u + uint64(d), nil
+	case float32, float64:
+		return rv.Float() + d, nil
+	default:
+		return nil, badRequestf("field %s to increase must be a number", field.Name)
+	}
+}
+
+// 追加空格分隔的标签，已有的标签不重复追加
+func appendTags(tags, newTags string) string {
+	res := whitespaceTokenize(tags)
+	has := make(map[string]bool, len(res))
+	for _, t := range res {
+		has[t] = true
+	}
+	for _, t := range whitespaceTokenize(newTags) {
+		if !has[t] {
+			has[t] = true
+			res = append(res, t)
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// 删除空格分隔的标签
+func removeTags(tags, oldTags string) string {
+	removed := map[string]bool{}
+	for _, t := range whitespaceTokenize(oldTags) {
+		removed[t] = true
+	}
+	res := []string{}
+	for _, t := range whitespaceTokenize(tags) {
+		if !removed[t] {
+			res = append(res, t)
+		}
+	}
+	return strings.Join(res, " ")
+}
+
+// 按body中的$set/$inc/$unset/$append/$remove操作符修改所有满足q/fq/f条件的doc，返回修改成功、失败的doc数
+// 操作符与UpdateDoc相同，修改全部doc需要指定q=*
+func UpdateByQuery(index string, args *QueryArgs, body map[string]interface{}) (updated int, failed int, err error) {
+	if args, err = checkQueryConds(args); err != nil {
+		return 0, 0, err
+	}
+	if !running {
+		return 0, 0, fmt.Errorf("the service is stopped")
+	}
+
+	idx, err := initIndexer(index)
+	if err != nil {
+		return 0, 0, err
+	}
+	fields, updates, err := parseUpdateOps(body)
+	if err != nil {
+		return 0, 0, err
+	}
+	for fieldName := range fields {
+		return 0, 0, badRequestf("field %s must be updated with an operator such as $set", fieldName)
+	}
+	if updates.empty() {
+		return 0, 0, badRequestf("no field to update")
+	}
+	if err = updates.check(idx.schema); err != nil {
+		return 0, 0, err
+	}
+
+	idx.updateLock.Lock()
+	defer idx.updateLock.Unlock()
+
+	// 先取出所有doc再修改，不在搜索过程中修改索引
+	matched, err := idx.matchDocs(args)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dones := make([]chan struct{}, 0, len(matched.docs))
+	for i, storedDoc := range matched.docs {
+		doc := idx.editableDoc(storedDoc)
+		done := make(chan struct{})
+		if err = updates.apply(doc, idx.schema); err == nil {
+			_, _, err = idx.sendIndexOp(doc, done)
+		}
+		if err != nil {
+			log.Printf("[error] updating doc %s of %s: %v\n", matched.docIds[i], idx.schema.Name, err)
+			failed++
+			continue
+		}
+		dones = append(dones, done)
+		updated++
+	}
+
+	// 修改的doc生效后才能解锁
+	for _, done := range dones {
+		<-done
+	}
+	if updated > 0 {
+		idx.flushSync()
+	}
+	log.Printf("[info] %d docs updated in index %s\n", updated, idx.schema.Name)
+	return updated, failed, nil
+}
@@ -0,0 +1,152 @@
+package indexer
+
+import (
+	"go-search/conf"
+	"testing"
+)
+
+func Test_fieldUpdates(t *testing.T) {
+	fields := []conf.Field{
+		{Name: "id", Type: "u32", PK: true},
+		{Name: "tags", Type: conf.StringStrType, Tokenizer: conf.WsTokenizer},
+		{Name: "stock", Type: "u32"},
+		{Name: "price", Type: "f32"},
+	}
+	schema := &indexSchema{Schema: &conf.Schema{SchemaConf: &conf.SchemaConf{Fields: fields}, FieldMap: map[string]int{}}}
+	for i := range fields {
+		schema.FieldMap[fields[i].Name] = i
+	}
+
+	u := &fieldUpdates{
+		Inc:    map[string]interface{}{"stock": float64(-2), "price": 0.5},
+		Append: map[string]interface{}{"tags": "b  c"},
+	}
+	if err := u.check(schema); err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]interface{}{"id": uint32(1), "tags": "a b", "stock": uint32(5)}
+	if err := u.apply(doc, schema); err != nil {
+		t.Fatal(err)
+	}
+	if doc["tags"] != "a b c" || doc["stock"] != uint64(3) || doc["price"] != float64(0.5) {
+		t.Errorf("unexpected doc %v", doc)
+	}
+
+	doc["stock"] = uint32(1)
+	if err := u.apply(doc, schema); err == nil {
+		t.Errorf("stock should be out of range")
+	}
+
+	for _, bad := range []*fieldUpdates{
+		{Set: map[string]interface{}{"id": 2}},
+		{Inc: map[string]interface{}{"stock": 1.5}},
+		{Inc: map[string]interface{}{"tags": float64(1)}},
+		{Set: map[string]interface{}{"stock": 1}, Unset: []string{"stock"}},
+		{Remove: map[string]interface{}{"price": "a"}},
+	} {
+		if err := bad.check(schema); err == nil {
+			t.Errorf("%+v should be rejected", bad)
+		}
+	}
+}
+
+func Test_parseUpdateOps(t *testing.T) {
+	doc := map[string]interface{}{
+		"id":      float64(1),
+		"$inc":    map[string]interface{}{"stock": float64(1)},
+		"$unset":  []interface{}{"price"},
+		"$remove": map[string]interface{}{"tags": "a"},
+	}
+	fields, u, err := parseUpdateOps(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields["id"] != float64(1) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if u.Inc["stock"] != float64(1) || len(u.Unset) != 1 || u.Unset[0] != "price" || u.Remove["tags"] != "a" {
+		t.Errorf("unexpected updates %+v", u)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"$push": map[string]interface{}{"tags": "a"}},
+		{"$inc": float64(1)},
+		{"$unset": "price"},
+	} {
+		if _, _, err := parseUpdateOps(bad); err == nil {
+			t.Errorf("%v should be rejected", bad)
+		}
+	}
+}
+
+func Test_UpdateByQuery(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	updates := map[string]interface{}{"$inc": map[string]interface{}{"price": float64(1)}}
+	// 没有条件时不修改
+	if _, _, err := UpdateByQuery(index, &QueryArgs{}, updates); err != ErrEmptyQuery || !IsBadRequest(err) {
+		t.Errorf("empty query should be rejected, got %v", err)
+	}
+	for _, args := range []*QueryArgs{{Q: "(shoes"}, {Q: "shoes AND"}} {
+		if _, _, err := UpdateByQuery(index, args, updates); !IsBadRequest(err) {
+			t.Errorf("%+v should be a bad request, got %v", args, err)
+		}
+	}
+
+	updated, failed, err := UpdateByQuery(index, &QueryArgs{Q: "shoes"}, updates)
+	if err != nil || updated != 2 || failed != 0 {
+		t.Errorf("expected 2 docs updated, got %d/%d, %v", updated, failed, err)
+	}
+	if updated, _, err = UpdateByQuery(index, &QueryArgs{Q: MatchAll}, updates); err != nil || updated != 4 {
+		t.Errorf("expected 4 docs updated, got %d, %v", updated, err)
+	}
+	docs, _ := GetDocs(index, []string{"1", "3"}, "")
+	if docs["1"]["price"] != float32(52) || docs["3"]["price"] != float32(301) {
+		t.Errorf("unexpected docs %v", docs)
+	}
+
+	// 与UpdateDoc使用相同的操作符，$remove删除标签，$unset删除字段
+	for _, bad := range []map[string]interface{}{
+		{"set": map[string]interface{}{"brand": "x"}},
+		{"brand": "x"},
+		{"$remove": []interface{}{"brand"}},
+		{},
+	} {
+		if _, _, err = UpdateByQuery(index, &QueryArgs{Q: MatchAll}, bad); !IsBadRequest(err) {
+			t.Errorf("%v should be rejected", bad)
+		}
+	}
+	if _, _, err = UpdateByQuery(index, &QueryArgs{Q: "hat"}, map[string]interface{}{"$unset": []interface{}{"brand"}}); err != nil {
+		t.Fatal(err)
+	}
+	if docs, _ = GetDocs(index, []string{"4"}, ""); docs["4"]["brand"] != nil || docs["4"]["name"] != "green hat running" {
+		t.Errorf("unexpected doc %v", docs["4"])
+	}
+}
+
+func Test_UpdateDoc(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	if _, _, err := UpdateDoc(index, map[string]interface{}{"id": float64(9), "name": "x"}, nil); err != ErrDocNotFound {
+		t.Errorf("updating a missing doc should return ErrDocNotFound, got %v", err)
+	}
+	if _, _, err := UpsertDoc(index, map[string]interface{}{"id": float64(9), "name": "x"}, nil); err != nil {
+		t.Errorf("upsert: %v", err)
+	}
+
+	// 请求不合法的错误
+	for _, bad := range []map[string]interface{}{
+		{"name": "x"},
+		{"id": "abc", "name": "x"},
+		{"id": float64(1), "price": "abc"},
+		{"id": float64(1), "$push": map[string]interface{}{"name": "x"}},
+		{"id": float64(1), "$set": map[string]interface{}{"id": float64(2)}},
+		{"id": float64(1), "$inc": map[string]interface{}{"name": float64(1)}},
+	} {
+		if _, _, err := UpdateDoc(index, bad, nil); !IsBadRequest(err) {
+			t.Errorf("%v should be a bad request, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package indexer
+
+import (
+	"github.com/go-ego/riot/types"
+)
+
+// 一个doc的打分说明。按逻辑表达式检索时搜索引擎不计算BM25、紧邻距离，排序只依据排序字段
+type Explanation struct {
+	DocId  string        `json:"doc-id"`
+	Sort   []SortExplain `json:"sort"`
+	Scores []float32     `json:"scores"` // 实际用于排序的打分
+}
+
+// 每个排序字段的值及其打分
+type SortExplain struct {
+	Field string      `json:"field"`
+	Asc   bool        `json:"asc"`
+	Value interface{} `json:"value"`
+	Score []float32   `json:"score"` // 该字段在打分中的各段
+}
+
+// 生成输出doc的打分说明
+func explainDoc(doc *types.ScoredDoc, storedDoc StoredDoc, pq *parsedQuery, schema *indexSchema) *Explanation {
+	scores := doc.Scores
+	exp := &Explanation{
+		DocId:  doc.DocId,
+		Sort:   make([]SortExplain, len(pq.sortBys)),
+		Scores: scores,
+	}
+	for i, sortBy := range pq.sortBys {
+		v := storedDoc[sortBy.fieldName]
+		if _, ok := schema.TimeIdx[sortBy.fieldName]; ok {
+			v = schema.Fields[sortBy.fIdx].FormatDatetime(v)
+		}
+		exp.Sort[i] = SortExplain{Field: sortBy.fieldName, Asc: sortBy.asc, Value: v}
+		if (i+1)*sortKeyWidth <= len(scores) {
+			exp.Sort[i].Score = scores[i*sortKeyWidth : (i+1)*sortKeyWidth]
+		}
+	}
+	return exp
+}
@@ -0,0 +1,46 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func Test_explain(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	args := &QueryArgs{Q: "shoes blue", F: "price:100~", S: "price:asc", PageSize: "1", Explain: true}
+	docs := queryDocs(t, index, args)
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 doc, got %d", len(docs))
+	}
+	exp, ok := docs[0]["_explain"].(*Explanation)
+	if !ok {
+		t.Fatalf("doc %v should have _explain", docs[0])
+	}
+	if exp.DocId != "2" {
+		t.Errorf("unexpected explanation %+v", exp)
+	}
+	if len(exp.Sort) != 1 || exp.Sort[0].Field != "price" || !exp.Sort[0].Asc || exp.Sort[0].Value != float32(150) {
+		t.Errorf("unexpected sort explanation %+v", exp.Sort)
+	}
+	if len(exp.Scores) != sortKeyWidth || len(exp.Sort[0].Score) != sortKeyWidth {
+		t.Errorf("unexpected scores %v", exp.Scores)
+	}
+
+	// 时间字段输出格式化后的值
+	docs = queryDocs(t, index, &QueryArgs{Q: "hat", Explain: true})
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 doc, got %d", len(docs))
+	}
+	exp = docs[0]["_explain"].(*Explanation)
+	if exp.Sort[0].Field != "ts" || exp.Sort[0].Value != "2019-12-04 10:00:00" {
+		t.Errorf("unexpected sort explanation %+v", exp.Sort)
+	}
+
+	docs = queryDocs(t, index, &QueryArgs{Q: "shoes"})
+	for _, doc := range docs {
+		if _, ok := doc["_explain"]; ok {
+			t.Errorf("_explain should be output only with explain")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package indexer
+
+import (
+	"fmt"
+)
+
+// 导出所有满足q/fq/f条件的doc，不排序、不分页
+// 打分时只收集匹配的doc，不阻塞搜索引擎，搜索结束后再逐个输出；done关闭时停止导出
+// 返回输出的字段列表和doc，字段列表最后是VersionField
+func Export(index string, args *QueryArgs, done <-chan struct{}) ([]string, <-chan interface{}, error) {
+	if !running {
+		return nil, nil, fmt.Errorf("the service is stopped")
+	}
+
+	pq, err := parseQuery(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idx, err := initIndexer(index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sr, err := idx.pq2SearchQuery(pq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields := pq.outFieldList
+	if fields == nil {
+		fields = make([]string, len(idx.schema.Fields))
+		for i := range idx.schema.Fields {
+			fields[i] = idx.schema.Fields[i].Name
+		}
+	}
+	// 与jsonl格式一样输出版本号
+	fields = append(fields, VersionField)
+
+	matched := &matchedDocs{}
+	sr.RankOpts.ScoringCriteria.(*scorerT).matched = matched
+
+	docs := make(chan interface{})
+	go func() {
+		defer close(docs)
+		idx.engine.Search(*sr)
+		for _, storedDoc := range matched.docs {
+			select {
+			case docs <- idx.outputDoc(storedDoc, pq.outFieldList):
+			case <-done:
+				return
+			}
+		}
+	}()
+	return fields, docs, nil
+}
@@ -0,0 +1,62 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Export(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	fields, docs, err := Export(index, &QueryArgs{Q: "shoes", Fl: "id,name"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fields, ",") != "id,name,"+VersionField {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	var exported []StoredDoc
+	for doc := range docs {
+		d := doc.(StoredDoc)
+		if _, ok := d[VersionField]; !ok {
+			t.Errorf("doc %v should have %s", d, VersionField)
+		}
+		if _, ok := d["brand"]; ok {
+			t.Errorf("field brand should not be exported")
+		}
+		exported = append(exported, d)
+	}
+	if ids := docIds(exported); ids != "1,2" {
+		t.Errorf("expected docs 1,2, got %s", ids)
+	}
+
+	// 没有fl时输出schema中的所有字段
+	fields, docs, err = Export(index, &QueryArgs{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 6 || fields[5] != VersionField {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	exported = exported[:0]
+	for doc := range docs {
+		exported = append(exported, doc.(StoredDoc))
+	}
+	if ids := docIds(exported); ids != "1,2,3,4" {
+		t.Errorf("expected all docs, got %s", ids)
+	}
+
+	// 停止导出后不再输出
+	done := make(chan struct{})
+	close(done)
+	if _, docs, err = Export(index, &QueryArgs{}, done); err != nil {
+		t.Fatal(err)
+	}
+	for range docs {
+	}
+
+	if _, _, err = Export(index, &QueryArgs{Fl: "unknown"}, nil); err == nil {
+		t.Errorf("unknown out field should be rejected")
+	}
+}
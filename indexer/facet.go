@@ -0,0 +1,96 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	// 每个facet字段缺省输出的值个数
+	DefaultFacetSize = 10
+)
+
+// 一个facet值及匹配的doc数
+type FacetValue struct {
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+}
+
+// 统计所有匹配的doc中facet字段的值，打分函数会在多个shard中并发调用
+type facetCounter struct {
+	lock   sync.Mutex
+	schema *indexSchema
+	fields []facetField
+	size   int
+}
+
+type facetField struct {
+	fieldName string
+	fIdx      int
+	counts    map[interface{}]int
+}
+
+func newFacetCounter(schema *indexSchema, fieldNames []string, size int) (*facetCounter, error) {
+	if len(fieldNames) == 0 {
+		return nil, nil
+	}
+
+	fields := make([]facetField, len(fieldNames))
+	for i, fieldName := range fieldNames {
+		fIdx, ok := schema.FieldMap[fieldName]
+		if !ok {
+			return nil, fmt.Errorf("facet field %s not found", fieldName)
+		}
+		if schema.Fields[fIdx].Type == "json" {
+			return nil, fmt.Errorf("facet field %s can not be json", fieldName)
+		}
+		fields[i] = facetField{fieldName: fieldName, fIdx: fIdx, counts: map[interface{}]int{}}
+	}
+	return &facetCounter{schema: schema, fields: fields, size: size}, nil
+}
+
+func (fc *facetCounter) add(doc StoredDoc) {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	for i := range fc.fields {
+		f := &fc.fields[i]
+		if v, ok := doc[f.fieldName]; ok && v != nil {
+			f.counts[v]++
+		}
+	}
+}
+
+// 输出每个字段中doc数最多的值
+func (fc *facetCounter) result() map[string][]FacetValue {
+	fc.lock.Lock()
+	defer fc.lock.Unlock()
+
+	res := make(map[string][]FacetValue, len(fc.fields))
+	for i := range fc.fields {
+		f := &fc.fields[i]
+		values := make([]FacetValue, 0, len(f.counts))
+		for v, c := range f.counts {
+			values = append(values, FacetValue{Value: v, Count: c})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return fmt.Sprintf("%v", values[i].Value) < fmt.Sprintf("%v", values[j].Value)
+		})
+		if len(values) > fc.size {
+			values = values[:fc.size]
+		}
+
+		field := &fc.schema.Fields[f.fIdx]
+		if _, ok := fc.schema.TimeIdx[f.fieldName]; ok {
+			for j := range values {
+				values[j].Value = field.FormatDatetime(values[j].Value)
+			}
+		}
+		res[f.fieldName] = values
+	}
+	return res
+}
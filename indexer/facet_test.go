@@ -0,0 +1,48 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_Query_facets(t *testing.T) {
+	index, teardown := setupTestIndex(t, testSchemaJSON, testDocs)
+	defer teardown()
+
+	cases := []struct {
+		args     QueryArgs
+		expected map[string]string
+	}{
+		{QueryArgs{Facet: "brand,price"}, map[string]string{
+			"brand": "[{acme 3} {foo 1}]",
+			"price": "[{150 1} {300 1} {50 1} {700 1}]",
+		}},
+		// 只统计满足条件的doc，doc数相同时按值排序
+		{QueryArgs{Q: "blue", Facet: "brand"}, map[string]string{"brand": "[{acme 1} {foo 1}]"}},
+		{QueryArgs{F: "price:~200", Facet: "brand"}, map[string]string{"brand": "[{acme 2}]"}},
+		{QueryArgs{Facet: "brand", FacetSize: "1"}, map[string]string{"brand": "[{acme 3}]"}},
+		{QueryArgs{Q: "hat", Facet: "ts"}, map[string]string{"ts": "[{2019-12-04 10:00:00 1}]"}},
+		{QueryArgs{Q: "nothing", Facet: "brand"}, map[string]string{"brand": "[]"}},
+	}
+	for i, c := range cases {
+		res, err := Query(index, &c.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Facets) != len(c.expected) {
+			t.Errorf("case #%d: unexpected facets %v", i, res.Facets)
+		}
+		for f, expected := range c.expected {
+			if values := fmt.Sprintf("%v", res.Facets[f]); values != expected {
+				t.Errorf("case #%d: expected %s facets %s, got %s", i, f, expected, values)
+			}
+		}
+	}
+
+	if res, _ := Query(index, &QueryArgs{}); res.Facets != nil {
+		t.Errorf("facets should be nil without facet argument")
+	}
+	if _, err := Query(index, &QueryArgs{Facet: "unknown"}); err == nil {
+		t.Errorf("unknown facet field should be rejected")
+	}
+}
@@ -0,0 +1,327 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// 缺省的高亮标签
+	DefaultHlPre  = "<em>"
+	DefaultHlPost = "</em>"
+	// 缺省的片段长度(字符数)，0表示输出整个字段
+	DefaultHlFragSize = 100
+	// 每个字段缺省最多输出的片段数
+	DefaultHlSnippets = 3
+)
+
+// 高亮参数
+type highlight struct {
+	fields   []string
+	pre      string
+	post     string
+	fragSize int
+	snippets int
+	terms    map[int]map[string]bool // 字段序号 -> 查询词，-1表示没有指定字段的查询词
+}
+
+// 检查高亮字段，并从q的语法树中收集需要高亮的查询词
+func (idx *indexer) prepareHighlight(hl *highlight, expr qNode) error {
+	if hl == nil {
+		return nil
+	}
+	for _, fieldName := range hl.fields {
+		fIdx, ok := idx.schema.FieldMap[fieldName]
+		if !ok {
+			return fmt.Errorf("highlight field %s not found", fieldName)
+		}
+		switch idx.schema.Fields[fIdx].Type {
+		case conf.StringType, conf.StringStrType:
+		default:
+			return fmt.Errorf("highlight field %s must be string", fieldName)
+		}
+	}
+	hl.terms = map[int]map[string]bool{}
+	collectHlTerms(expr, hl.terms)
+	return nil
+}
+
+// 只收集must、should中的查询词，notIn中的词不会出现在结果中
+func collectHlTerms(node qNode, terms map[int]map[string]bool) {
+	switch n := node.(type) {
+	case *termNode:
+		words := n.words
+		if len(words) == 0 && n.fIdx >= 0 {
+			// 不分词的字段
+			words = []string{unquote(n.text)}
+		}
+		for _, w := range words {
+			if terms[n.fIdx] == nil {
+				terms[n.fIdx] = map[string]bool{}
+			}
+			terms[n.fIdx][w] = true
+		}
+	case *boolNode:
+		for _, nodes := range [][]qNode{n.must, n.should} {
+			for _, c := range nodes {
+				collectHlTerms(c, terms)
+			}
+		}
+	}
+}
+
+// 一个匹配的token在字段值中的字节位置
+type hlSpan struct {
+	start, end int
+}
+
+// 生成一个doc的高亮结果: 字段名 -> 片段列表，没有匹配的字段不输出
+func (hl *highlight) doc(doc StoredDoc, schema *indexSchema) map[string][]string {
+	res := map[string][]string{}
+	for _, fieldName := range hl.fields {
+		s, ok := doc[fieldName].(string)
+		if !ok || len(s) == 0 {
+			continue
+		}
+		fIdx := schema.FieldMap[fieldName]
+		spans := hl.matchSpans(s, fIdx, fieldAnalyzer(schema, fIdx))
+		if len(spans) == 0 {
+			continue
+		}
+		res[fieldName] = hl.fragments(s, spans)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func (hl *highlight) isTerm(fIdx int, token string) bool {
+	return hl.terms[fIdx][token] || hl.terms[-1][token]
+}
+
+// 用字段建索引时的分析器分词，找出匹配查询词的token在字段值中的位置，analyzer为nil表示不分词
+// 按空白分隔的每一段分别分析，能在原文中找到的token按原位置高亮；
+// 被分析器转换过(如词干、规范化)找不到的token高亮整段，只是一个词的一部分时高亮整个词
+func (hl *highlight) matchSpans(s string, fIdx int, analyzer Analyzer) []hlSpan {
+	if analyzer == nil {
+		return hl.wholeSpan(s, fIdx, strings.TrimSpace(s))
+	}
+	// keyword等不按空白分词的分析器，整个字段值是一个token
+	if tokens := analyzer.Analyze(s); len(tokens) == 1 && strings.IndexFunc(tokens[0], unicode.IsSpace) >= 0 {
+		return hl.wholeSpan(s, fIdx, tokens[0])
+	}
+
+	var spans []hlSpan
+	for _, c := range spaceSeparated(s) {
+		chunk := s[c.start:c.end]
+		pos := 0
+		for _, token := range analyzer.Analyze(chunk) {
+			i := indexToken(chunk[pos:], token)
+			if i >= 0 {
+				i += pos
+				pos = i + len(token)
+			}
+			if !hl.isTerm(fIdx, token) {
+				continue
+			}
+			var sp hlSpan
+			if i < 0 {
+				sp = trimNonWord(chunk)
+			} else {
+				sp = expandToWord(chunk, i, i+len(token))
+			}
+			if sp.start < sp.end {
+				spans = append(spans, hlSpan{c.start + sp.start, c.start + sp.end})
+			}
+		}
+	}
+	return mergeSpans(spans)
+}
+
+// 整个字段值作为一个token，匹配时高亮去掉首尾空白的字段值
+func (hl *highlight) wholeSpan(s string, fIdx int, token string) []hlSpan {
+	if !hl.isTerm(fIdx, token) {
+		return nil
+	}
+	v := strings.TrimSpace(s)
+	start := strings.Index(s, v)
+	return []hlSpan{{start, start + len(v)}}
+}
+
+// 按空白分隔的各段的位置
+func spaceSeparated(s string) []hlSpan {
+	var res []hlSpan
+	start := -1
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			if start >= 0 {
+				res = append(res, hlSpan{start, i})
+				start = -1
+			}
+		case start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		res = append(res, hlSpan{start, len(s)})
+	}
+	return res
+}
+
+// token在s中的位置，token可能被lowercase等过滤器转换过，找不到时返回-1
+func indexToken(s, token string) int {
+	if i := strings.Index(s, token); i >= 0 {
+		return i
+	}
+	if lower := strings.ToLower(s); len(lower) == len(s) {
+		return strings.Index(lower, token)
+	}
+	return -1
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// 中文按分词结果高亮，不扩展到整个词
+func isExpandable(r rune) bool {
+	return isWordRune(r) && !unicode.Is(unicode.Han, r)
+}
+
+// 把[start, end)扩展到所在的整个词，如词干"run"扩展为"running"
+func expandToWord(s string, start, end int) hlSpan {
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:start])
+		if !isExpandable(r) {
+			break
+		}
+		start -= size
+	}
+	for end < len(s) {
+		r, size := utf8.DecodeRuneInString(s[end:])
+		if !isExpandable(r) {
+			break
+		}
+		end += size
+	}
+	return hlSpan{start, end}
+}
+
+// 去掉首尾的标点等非文字字符
+func trimNonWord(s string) hlSpan {
+	start := len(s) - len(strings.TrimLeftFunc(s, func(r rune) bool { return !isWordRune(r) }))
+	end := len(strings.TrimRightFunc(s, func(r rune) bool { return !isWordRune(r) }))
+	if end < start {
+		end = start
+	}
+	return hlSpan{start, end}
+}
+
+// 合并相邻的token，如中文的单字
+func mergeSpans(spans []hlSpan) []hlSpan {
+	if len(spans) == 0 {
+		return nil
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	res := spans[:1]
+	for _, sp := range spans[1:] {
+		last := &res[len(res)-1]
+		if sp.start <= last.end {
+			if sp.end > last.end {
+				last.end = sp.end
+			}
+			continue
+		}
+		res = append(res, sp)
+	}
+	return res
+}
+
+// 一个片段在字段值中的位置，及其包含的匹配token spans[i:j]
+type hlFragment struct {
+	start, end int
+	i, j       int
+}
+
+// 把匹配的token加上标签，截取包含匹配token的片段，重叠的片段合并为一个
+func (hl *highlight) fragments(s string, spans []hlSpan) []string {
+	if hl.fragSize <= 0 || utf8.RuneCountInString(s) <= hl.fragSize {
+		return []string{hl.tag(s, 0, len(s), spans)}
+	}
+
+	var frags []hlFragment
+	for i := 0; i < len(spans); {
+		// 匹配的token前面保留1/4片段长度的上下文
+		start := backRunes(s, spans[i].start, hl.fragSize/4)
+		end := forwardRunes(s, start, hl.fragSize)
+		// 片段不能截断匹配的token
+		j := i
+		for j < len(spans) && spans[j].start < end {
+			if spans[j].end > end {
+				end = spans[j].end
+			}
+			j++
+		}
+		if n := len(frags); n > 0 && start <= frags[n-1].end {
+			frags[n-1].end, frags[n-1].j = end, j
+		} else if n < hl.snippets {
+			frags = append(frags, hlFragment{start, end, i, j})
+		} else {
+			break
+		}
+		i = j
+	}
+
+	res := make([]string, len(frags))
+	for k, f := range frags {
+		frag := hl.tag(s, f.start, f.end, spans[f.i:f.j])
+		if f.start > 0 {
+			frag = "..." + frag
+		}
+		if f.end < len(s) {
+			frag += "..."
+		}
+		res[k] = frag
+	}
+	return res
+}
+
+// 字段值做HTML转义，高亮标签原样输出
+func (hl *highlight) tag(s string, start, end int, spans []hlSpan) string {
+	sb := &strings.Builder{}
+	pos := start
+	for _, sp := range spans {
+		sb.WriteString(html.EscapeString(s[pos:sp.start]))
+		sb.WriteString(hl.pre)
+		sb.WriteString(html.EscapeString(s[sp.start:sp.end]))
+		sb.WriteString(hl.post)
+		pos = sp.end
+	}
+	sb.WriteString(html.EscapeString(s[pos:end]))
+	return sb.String()
+}
+
+// 从pos向前n个字符的位置
+func backRunes(s string, pos, n int) int {
+	for ; n > 0 && pos > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:pos])
+		pos -= size
+	}
+	return pos
+}
+
+// 从pos向后n个字符的位置
+func forwardRunes(s string, pos, n int) int {
+	for ; n > 0 && pos < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+	return pos
+}
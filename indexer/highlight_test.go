@@ -0,0 +1,109 @@
+package indexer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_highlight(t *testing.T) {
+	idx := &indexer{schema: testSchema()}
+	doc := StoredDoc{"id": uint32(1), "name": "red shoes, red hat", "brand": "acme", "title": "红色的鞋"}
+
+	cases := map[string]map[string]string{
+		"red title:红色":      {"name": "<em>red</em> shoes, <em>red</em> hat", "title": "<em>红色</em>的鞋"},
+		"brand:acme -shoes": {"brand": "<em>acme</em>"},
+		`"red hat" OR 鞋`:    {"name": "<em>red</em> shoes, <em>red</em> <em>hat</em>", "title": "红色的<em>鞋</em>"},
+		"name:鞋":            {},
+	}
+	for q, expected := range cases {
+		node, _ := parseQExpr(q)
+		hl := &highlight{fields: []string{"name", "brand", "title"}, pre: "<em>", post: "</em>", fragSize: 100, snippets: 1}
+		if err := idx.prepareHighlight(hl, idx.resolveQNode(node)); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		res := hl.doc(doc, idx.schema)
+		if len(res) != len(expected) {
+			t.Errorf("%s: expected %v, got %v", q, expected, res)
+			continue
+		}
+		for f, frag := range expected {
+			if len(res[f]) != 1 || res[f][0] != frag {
+				t.Errorf("%s: expected %s, got %v", q, frag, res[f])
+			}
+		}
+	}
+
+	hl := &highlight{pre: "[", post: "]", fragSize: 6, snippets: 2}
+	if frags := hl.fragments("aaaa bb cccc bb dddd bb", []hlSpan{{5, 7}, {13, 15}, {21, 23}}); fmt.Sprintf("%q", frags) != `["... [bb] cc..." "... [bb] dd..."]` {
+		t.Errorf("unexpected fragments: %q", frags)
+	}
+}
+
+func Test_matchSpans(t *testing.T) {
+	normalized := NewAnalyzer(
+		[]CharFilter{charFilters["nfkc"], charFilters["lowercase"]},
+		TokenizerFunc(spaceTokenizer),
+		nil,
+	)
+
+	cases := []struct {
+		analyzer Analyzer
+		s        string
+		terms    []string
+		expected string
+	}{
+		// 词干高亮整个词
+		{analyzers["english"], "Running shoes", []string{"run"}, "<em>Running</em> shoes"},
+		{analyzers["english"], "The runner runs", []string{"run"}, "The runner <em>runs</em>"},
+		{analyzers["english"], "running, walking", []string{"run", "walk"}, "<em>running</em>, <em>walking</em>"},
+		// 规范化后在原文中找不到的token高亮整段
+		{normalized, "ＡＰＰＬＥ pie", []string{"apple"}, "<em>ＡＰＰＬＥ</em> pie"},
+		{analyzers["space"], "red Shoes", []string{"shoes"}, ""},
+		{analyzers["space"], "red shoes", []string{"shoes"}, "red <em>shoes</em>"},
+		// HTML转义
+		{analyzers["space"], "<b>red</b> & shoes", []string{"shoes"}, "&lt;b&gt;red&lt;/b&gt; &amp; <em>shoes</em>"},
+		// 整个字段值是一个token
+		{analyzers["keyword"], " Blue Shirt ", []string{"Blue Shirt"}, " <em>Blue Shirt</em> "},
+		{nil, "acme", []string{"acme"}, "<em>acme</em>"},
+	}
+	for i, c := range cases {
+		hl := &highlight{pre: DefaultHlPre, post: DefaultHlPost, fragSize: DefaultHlFragSize, snippets: DefaultHlSnippets,
+			terms: map[int]map[string]bool{-1: {}}}
+		for _, term := range c.terms {
+			hl.terms[-1][term] = true
+		}
+		res := ""
+		if spans := hl.matchSpans(c.s, 0, c.analyzer); len(spans) > 0 {
+			res = strings.Join(hl.fragments(c.s, spans), "|")
+		}
+		if res != c.expected {
+			t.Errorf("case #%d: expected %q, got %q", i, c.expected, res)
+		}
+	}
+}
+
+func Test_fragments(t *testing.T) {
+	s := "aaa bbb ccc ddd eee fff ggg hhh iii jjj kkk lll"
+	cases := []struct {
+		terms    []string
+		snippets int
+		expected []string
+	}{
+		{[]string{"bbb", "kkk"}, 3, []string{"...a <em>bbb</em> ccc ...", "...j <em>kkk</em> lll"}},
+		// 重叠的片段合并为一个
+		{[]string{"bbb", "ddd"}, 3, []string{"...a <em>bbb</em> ccc <em>ddd</em> eee ..."}},
+		{[]string{"bbb", "ggg", "kkk"}, 2, []string{"...a <em>bbb</em> ccc ...", "...f <em>ggg</em> hhh ..."}},
+	}
+	for i, c := range cases {
+		hl := &highlight{pre: DefaultHlPre, post: DefaultHlPost, fragSize: 10, snippets: c.snippets,
+			terms: map[int]map[string]bool{0: {}}}
+		for _, term := range c.terms {
+			hl.terms[0][term] = true
+		}
+		frags := hl.fragments(s, hl.matchSpans(s, 0, analyzers["space"]))
+		if strings.Join(frags, "|") != strings.Join(c.expected, "|") {
+			t.Errorf("case #%d: expected %q, got %q", i, c.expected, frags)
+		}
+	}
+}
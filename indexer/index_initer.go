@@ -4,6 +4,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"go-search/conf"
+	"io"
 	"log"
 	"time"
 
@@ -23,14 +24,18 @@ func initIndexer(index string) (*indexer, error) {
 		return idx, nil
 	}
 
-	schema, err := conf.LoadSchema(index)
+	schemaConf, err := conf.LoadSchema(index)
 	if err != nil {
 		return nil, fmt.Errorf("schema of %s not found, please create schema first", index)
 	}
+	schema, err := newIndexSchema(schemaConf)
+	if err != nil {
+		return nil, fmt.Errorf("bad analyzers in schema of %s: %v", index, err)
+	}
 
 	gob.Register(StoredDoc{})
 	engine := &riot.Engine{}
-	idx = &indexer{schema: schema, engine: engine}
+	idx = &indexer{schema: schema, engine: engine, dict: newTermDict(), docs: newDocMap()}
 	initOpts := types.EngineOpts{
 		UseStore:  len(conf.UseStore) > 0,
 		NotUseGse: true,
@@ -39,31 +44,30 @@ func initIndexer(index string) (*indexer, error) {
 			StoreFolder: schema.StorePath,
 			NumShards:   int(schema.Shards),
 		*/
-		//IndexerOpts: &types.IndexerOpts{
-		//	IndexType: types.LocsIndex,
-		//},
+		// 保存token的位置，用于判断短语中的token是否依次出现
+		IndexerOpts: &types.IndexerOpts{
+			IndexType: types.LocsIndex,
+		},
 	}
 	if len(conf.UseStore) > 0 {
 		initOpts.StoreEngine = conf.UseStore
 		initOpts.StoreFolder = schema.StorePath
 		initOpts.NumShards = int(schema.Shards)
 	}
-	//if schema.NeedZhSeg {
-	//	segDict := &conf.ServiceConf.SegDict
-	//	initOpts.Using = 3
-	//	initOpts.GseDict = segDict.DictFile
-	//	initOpts.StopTokenFile = segDict.StopFile
-	//} else {
-	//	initOpts.NotUseGse = true
-	//}
+	// zh字段的分词不使用riot内置的gse，见zhTokenize()
 	engine.Init(initOpts)
 	engine.Flush()
+	if initOpts.UseStore {
+		idx.loadDocMap()
+	}
 	log.Printf("[LRU] index %s (new) added to LRU\n", index)
 	lruAdd(index)
 
 	indexerLock.Lock()
 	defer indexerLock.Unlock()
 
+	// 和SetSynonyms()互斥，保证不会用到旧的同义词
+	idx.loadSynonyms()
 	indexers[index] = idx
 	return idx, nil
 }
@@ -86,6 +90,18 @@ func RemoveIndexer(index string) {
 	}()
 }
 
+// 保存索引库的schema，字段的分析器不合法时不保存
+func SaveSchema(index string, in io.Reader) error {
+	schemaConf, err := conf.ParseSchema(index, in)
+	if err != nil {
+		return err
+	}
+	if _, _, err = buildAnalyzers(schemaConf); err != nil {
+		return err
+	}
+	return conf.SaveSchema(index, schemaConf)
+}
+
 // -------------------------------------
 
 const (
@@ -99,6 +115,8 @@ type indexerOp struct {
 	engine *riot.Engine
 	docID  string
 	doc    *types.DocData
+	docs   *docMap       // 写入搜索引擎后同步修改
+	done   chan struct{} // 不为nil时，操作完成后关闭
 }
 
 var (
@@ -153,11 +171,16 @@ func opThread(workNo int) {
 		switch op {
 		case TypeIndexDoc:
 			engine.IndexDoc(docID, *doc, true)
+			opData.docs.set(docID, doc.Fields.(StoredDoc))
 		case TypeDeleteDoc:
 			engine.RemoveDoc(docID, true)
+			opData.docs.remove(docID)
 		case TypeFlushDoc:
 			engine.Flush()
 		}
+		if opData.done != nil {
+			close(opData.done)
+		}
 	}
 
 	stopChan <- struct{}{}
@@ -0,0 +1,62 @@
+package indexer
+
+import (
+	"encoding/json"
+	"go-search/conf"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testSchemaJSON = `{"fields": [
+	{"name": "id", "type": "u32", "pk": true},
+	{"name": "name"},
+	{"name": "brand", "tokenizer": "none"},
+	{"name": "price", "type": "f32"},
+	{"name": "ts", "type": "datetime", "sorting": "desc"}
+]}`
+
+var testDocs = jsonDocs(`[
+	{"id": 1, "name": "red shoes", "brand": "acme", "price": 50, "ts": "2019-10-01 10:00:00"},
+	{"id": 2, "name": "blue shoes used", "brand": "acme", "price": 150, "ts": "2019-10-02 10:00:00"},
+	{"id": 3, "name": "blue shirt", "brand": "foo", "price": 300, "ts": "2019-11-03 10:00:00"},
+	{"id": 4, "name": "green hat running", "brand": "acme", "price": 700, "ts": "2019-12-04 10:00:00"}
+]`)
+
+// 与REST接口收到的doc一样，数值为float64
+func jsonDocs(s string) []map[string]interface{} {
+	var docs []map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &docs); err != nil {
+		panic(err)
+	}
+	return docs
+}
+
+// 在临时目录中用schemaJSON创建索引库并写入docs，返回索引库名，测试结束时删除
+func setupTestIndex(t *testing.T, schemaJSON string, docs []map[string]interface{}) (string, func()) {
+	dir, err := ioutil.TempDir("", "go-search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedRoot := conf.ServiceConf.RootDir
+	conf.ServiceConf.RootDir = dir
+	if !running {
+		StartIndexers(2)
+	}
+
+	index := strings.Replace(t.Name(), "/", "_", -1)
+	if err = SaveSchema(index, strings.NewReader(schemaJSON)); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range docs {
+		if _, _, err = IndexDoc(index, doc, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return index, func() {
+		RemoveIndexer(index)
+		conf.ServiceConf.RootDir = savedRoot
+		os.RemoveAll(dir)
+	}
+}
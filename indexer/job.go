@@ -0,0 +1,182 @@
+package indexer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go-search/conf"
+	"sync"
+	"time"
+)
+
+// 异步导入任务的状态
+const (
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobCanceled = "canceled"
+)
+
+const (
+	maxJobErrors = 100            // 每个任务最多记录的错误数
+	jobKeepTime  = 24 * time.Hour // 结束的任务保留的时间
+	jobTimeFmt   = "2006-01-02 15:04:05"
+)
+
+// 一个doc的导入错误
+type JobError struct {
+	Doc   int    `json:"doc"` // 第几个doc，从1开始，不是输入的行号(CSV的标题行不计算在内)
+	Error string `json:"error"`
+}
+
+// 任务状态，GET /jobs/:id的输出
+type JobStatus struct {
+	Id        string       `json:"id"`
+	Index     string       `json:"index"`
+	State     string       `json:"state"`
+	Docs      int          `json:"docs"`   // 导入成功的doc数
+	Failed    int          `json:"failed"` // 导入失败的doc数
+	Errors    []JobError   `json:"errors,omitempty"`
+	StartTime string       `json:"start-time"`
+	EndTime   string       `json:"end-time,omitempty"`
+	Elapsed   float64      `json:"elapsed"` // 已经执行的秒数
+	Callback  *CallbackLog `json:"callback,omitempty"`
+}
+
+// 异步导入任务
+type Job struct {
+	lock     sync.Mutex
+	status   JobStatus
+	start    time.Time
+	end      time.Time
+	callback string
+	canceled chan struct{}
+}
+
+var (
+	jobs     = map[string]*Job{}
+	jobsLock sync.Mutex
+)
+
+func newJob(index, callback string) *Job {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	now := time.Now()
+	job := &Job{
+		status: JobStatus{
+			Id:        hex.EncodeToString(b),
+			Index:     index,
+			State:     JobRunning,
+			StartTime: now.In(conf.Loc).Format(jobTimeFmt),
+		},
+		start:    now,
+		callback: callback,
+		canceled: make(chan struct{}),
+	}
+
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	pruneJobs(now)
+	if callback != "" {
+		job.status.Callback = &CallbackLog{Url: callback, State: CallbackPending}
+	}
+	jobs[job.status.Id] = job
+	return job
+}
+
+// 删除结束超过jobKeepTime的任务，调用时必须持有jobsLock
+func pruneJobs(now time.Time) {
+	for id, j := range jobs {
+		j.lock.Lock()
+		expired := j.status.State != JobRunning && now.Sub(j.end) > jobKeepTime
+		j.lock.Unlock()
+		if expired {
+			delete(jobs, id)
+		}
+	}
+}
+
+// 获取任务的当前状态，过期的任务返回不存在
+func GetJob(id string) (*JobStatus, error) {
+	jobsLock.Lock()
+	pruneJobs(time.Now())
+	job, ok := jobs[id]
+	jobsLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("job %s not found", id)
+	}
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	status := job.status
+	status.Errors = append([]JobError(nil), job.status.Errors...)
+	if cb := job.status.Callback; cb != nil {
+		status.Callback = &CallbackLog{Url: cb.Url, State: cb.State, Attempts: append([]CallbackAttempt(nil), cb.Attempts...)}
+	}
+	end := job.end
+	if status.State == JobRunning {
+		end = time.Now()
+	}
+	status.Elapsed = end.Sub(job.start).Seconds()
+	return &status, nil
+}
+
+// 取消正在执行的任务，已经导入的doc不会删除
+func CancelJob(id string) error {
+	jobsLock.Lock()
+	job, ok := jobs[id]
+	jobsLock.Unlock()
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if job.status.State != JobRunning {
+		return fmt.Errorf("job %s is %s", id, job.status.State)
+	}
+	select {
+	case <-job.canceled:
+	default:
+		close(job.canceled)
+	}
+	return nil
+}
+
+func (job *Job) isCanceled() bool {
+	select {
+	case <-job.canceled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (job *Job) addDoc() {
+	job.lock.Lock()
+	job.status.Docs++
+	job.lock.Unlock()
+}
+
+func (job *Job) addError(doc int, err error) {
+	job.lock.Lock()
+	job.status.Failed++
+	if len(job.status.Errors) < maxJobErrors {
+		job.status.Errors = append(job.status.Errors, JobError{Doc: doc, Error: err.Error()})
+	}
+	job.lock.Unlock()
+}
+
+// 任务结束，返回最终状态
+func (job *Job) finish() JobStatus {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	job.end = time.Now()
+	if job.isCanceled() {
+		job.status.State = JobCanceled
+	} else {
+		job.status.State = JobDone
+	}
+	job.status.EndTime = job.end.In(conf.Loc).Format(jobTimeFmt)
+	job.status.Elapsed = job.end.Sub(job.start).Seconds()
+	return job.status
+}
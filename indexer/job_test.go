@@ -0,0 +1,77 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_job(t *testing.T) {
+	job := newJob("test", "")
+	id := job.status.Id
+	defer func() {
+		jobsLock.Lock()
+		delete(jobs, id)
+		jobsLock.Unlock()
+	}()
+
+	job.addDoc()
+	job.addDoc()
+	for i := 0; i < maxJobErrors+5; i++ {
+		job.addError(i+3, fmt.Errorf("bad doc"))
+	}
+	status, err := GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != JobRunning || status.Docs != 2 || status.Failed != maxJobErrors+5 || len(status.Errors) != maxJobErrors {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status.Errors[0].Doc != 3 || status.EndTime != "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	// 取消后任务结束时才是canceled状态，可以重复取消
+	if err = CancelJob(id); err != nil {
+		t.Fatal(err)
+	}
+	if !job.isCanceled() {
+		t.Errorf("job should be canceled")
+	}
+	if err = CancelJob(id); err != nil {
+		t.Errorf("cancel a canceling job: %v", err)
+	}
+	if final := job.finish(); final.State != JobCanceled || final.EndTime == "" {
+		t.Errorf("unexpected final status %+v", final)
+	}
+	if err = CancelJob(id); err == nil {
+		t.Errorf("finished job can not be canceled")
+	}
+	if status, _ = GetJob(id); status.State != JobCanceled {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	done := newJob("test", "")
+	defer func() {
+		jobsLock.Lock()
+		delete(jobs, done.status.Id)
+		jobsLock.Unlock()
+	}()
+	if final := done.finish(); final.State != JobDone || final.Docs != 0 {
+		t.Errorf("unexpected final status %+v", final)
+	}
+
+	// 结束超过保留时间的任务在查询时删除
+	job.lock.Lock()
+	job.end = time.Now().Add(-jobKeepTime - time.Second)
+	job.lock.Unlock()
+	if _, err = GetJob(id); err == nil {
+		t.Errorf("expired job should be removed")
+	}
+	if _, err = GetJob(done.status.Id); err != nil {
+		t.Errorf("job just finished should be kept: %v", err)
+	}
+	if _, err = GetJob("no-such-job"); err == nil {
+		t.Errorf("unknown job should not be found")
+	}
+}
@@ -3,67 +3,94 @@ package indexer
 import (
 	"fmt"
 	"go-search/conf"
-	"math"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-ego/riot/types"
 )
 
 // 根据参数完成实际的搜索查询
-func Query(
-	index, q, fq, s, f, page, pagesize, fl string,
-) (pagination interface{}, timeout bool, docs <-chan interface{}, err error) {
+func Query(index string, args *QueryArgs) (*QueryResult, error) {
 	if !running {
-		return nil, false, nil, fmt.Errorf("the service is stopped")
+		return nil, fmt.Errorf("the service is stopped")
 	}
 
-	pq, err := parseQuery(q, fq, s, f, page, pagesize, fl)
+	pq, err := parseQuery(args)
 	if err != nil {
-		return nil, false, nil, err
+		return nil, err
 	}
 
 	idx, err := initIndexer(index)
 	if err != nil {
-		return nil, false, nil, err
+		return nil, err
 	}
 
 	sr, err := idx.pq2SearchQuery(pq)
 	if err != nil {
-		return nil, false, nil, err
+		return nil, err
 	}
 	fmt.Printf("pq: %#v\n", pq)
 	fmt.Printf("sr: %v\n", *sr)
 
-	resp := idx.engine.Search(*sr)
-	pagination, timeout, docs = idx.outputResult(&resp, pq)
-	return
+	resp := idx.search(sr, pq)
+	res := &QueryResult{}
+	res.Pagination, res.Timeout, res.Docs = idx.outputResult(&resp, pq)
+	if facets := sr.RankOpts.ScoringCriteria.(*scorerT).facets; facets != nil {
+		res.Facets = facets.result()
+	}
+	if aggs := sr.RankOpts.ScoringCriteria.(*scorerT).aggs; aggs != nil {
+		res.Aggs = aggs.result()
+	}
+	return res, nil
 }
 
-// 转换为搜索引擎的搜索参数
+// 转换为搜索引擎的搜索参数，参数与索引库的schema不符时返回badRequestError
 func (idx *indexer) pq2SearchQuery(pq *parsedQuery) (*types.SearchReq, error) {
 	// fl
 	fm := idx.schema.FieldMap
 	if pq.outFieldList != nil && len(pq.outFieldList) > 0 {
 		for _, fn := range pq.outFieldList {
 			if _, ok := fm[fn]; !ok {
-				return nil, fmt.Errorf("out field %s not found", fn)
+				return nil, badRequestf("out field %s not found", fn)
 			}
 		}
 	}
 
+	// facet
+	facets, err := newFacetCounter(idx.schema, pq.facetFields, pq.facetSize)
+	if err != nil {
+		return nil, badRequestError{err}
+	}
+	// agg
+	aggs, err := newAggregator(idx.schema, pq.aggs)
+	if err != nil {
+		return nil, badRequestError{err}
+	}
+
 	sr := types.SearchReq{
 		RankOpts: &types.RankOpts{
 			ScoringCriteria: &scorerT{
 				schema: idx.schema,
 				pq:     pq,
+				facets: facets,
+				aggs:   aggs,
 			},
 			OutputOffset: pq.start,
 			MaxOutputs:   pq.rows,
 		},
 	}
 
-	if pq.labels == nil {
+	// fq并入q的语法树
+	for _, fq := range pq.fquerys {
+		if _, ok := fm[fq.fieldName]; ok {
+			pq.expr = mergeQNode(pq.expr, fq.expr)
+		}
+	}
+	pq.fquerys = nil
+
+	if pq.expr != nil {
 		sr.Logic = types.Logic{
 			Expr: types.Expr{
 				Must:   []string{},
@@ -72,9 +99,24 @@ func (idx *indexer) pq2SearchQuery(pq *parsedQuery) (*types.SearchReq, error) {
 			},
 		}
 		// q
-		idx.generateTokens(pq.should, &sr.Logic.Should, &sr.Logic.Expr.Should)
-		idx.generateTokens(pq.must, &sr.Logic.Must, &sr.Logic.Expr.Must)
-		idx.generateTokens(pq.notIn, &sr.Logic.NotIn, &sr.Logic.Expr.NotIn)
+		pq.expr = idx.resolveQNode(pq.expr)
+	}
+
+	// hl，需要在q的语法树转换为query之前收集查询词
+	if err := idx.prepareHighlight(pq.hl, pq.expr); err != nil {
+		return nil, badRequestError{err}
+	}
+
+	if pq.expr != nil {
+		if q, fqs, ok := flattenQNode(pq.expr, idx.schema.Fields); ok {
+			pq.query, pq.fquerys, pq.expr = q, fqs, nil
+			idx.generateTokens(pq.should, &sr.Logic.Should, &sr.Logic.Expr.Should)
+			idx.generateTokens(pq.must, &sr.Logic.Must, &sr.Logic.Expr.Must)
+			idx.generateTokens(pq.notIn, &sr.Logic.NotIn, &sr.Logic.Expr.NotIn)
+		} else {
+			generateExprTokens(pq.expr, &sr.Logic)
+			idx.lookupQNode(pq.expr, map[string]map[string]bool{})
+		}
 	}
 
 	// fq
@@ -107,6 +149,19 @@ func (idx *indexer) pq2SearchQuery(pq *parsedQuery) (*types.SearchReq, error) {
 	if pq.sortBys == nil {
 		pq.sortBys = makeDefaultSortBys(idx.schema)
 	}
+	if pq.useCursor {
+		if pq.cursor, err = decodeCursor(pq.after, pq.sortBys, idx.schema); err != nil {
+			return nil, badRequestError{err}
+		}
+	}
+	if hasStringSorting(pq.sortBys, idx.schema) {
+		pq.sortAll = true
+		sr.RankOpts.OutputOffset = 0
+		sr.RankOpts.MaxOutputs = 0
+	} else if pq.cursor != nil {
+		// 打分时已经去掉了游标之前的doc，多取一个判断是否还有下一页
+		sr.RankOpts.MaxOutputs = pq.rows + 1
+	}
 
 	// f
 	checkFilters(&pq.filters, idx.schema)
@@ -114,13 +169,29 @@ func (idx *indexer) pq2SearchQuery(pq *parsedQuery) (*types.SearchReq, error) {
 	return &sr, nil
 }
 
+// 执行搜索，需要时对所有结果排序后分页
+func (idx *indexer) search(sr *types.SearchReq, pq *parsedQuery) types.SearchResp {
+	resp := idx.engine.Search(*sr)
+	if pq.sortAll {
+		sortResult(&resp, pq, idx.schema)
+	} else if pq.cursor != nil {
+		cutCursorPage(&resp, pq, idx.schema)
+	}
+	if pq.cursor != nil {
+		// 搜索引擎只返回了游标之后的doc数
+		resp.NumDocs = int(atomic.LoadInt64(&sr.RankOpts.ScoringCriteria.(*scorerT).total))
+	}
+	return resp
+}
+
 func (idx *indexer) generateTokens(qs []string, flag *bool, res *[]string) {
 	if len(qs) == 0 {
 		return
 	}
 	for _, q := range qs {
-		// *res = append(*res, idx.engine.Segment(q)...)
-		*res = append(*res, hanziTokenize(q)...)
+		for _, tokens := range idx.analyzeAnyField(q) {
+			*res = append(*res, tokens...)
+		}
 	}
 	if len(*res) > 0 {
 		*flag = true
@@ -132,29 +203,188 @@ func (idx *indexer) generateFieldTokens(fIdx int, qs []string, flag *bool, res *
 		return
 	}
 
-	tokenizer := idx.schema.Fields[fIdx].Tokenizer
 	c := 0
 	for _, q := range qs {
-		var tokens []string
-		switch tokenizer {
-		case conf.ZhTokenizer:
-			// tokens = idx.engine.Segment(q)
-			tokens = hanziTokenize(q)
-		case conf.NoneTokenizer:
-			// tokens = []string{strings.TrimSpace(q)}
-		default:
-			tokens = whitespaceTokenize(q)
-		}
+		tokens := idx.fieldTokens(fIdx, q)
 		c += len(tokens)
-		for _, t := range tokens {
-			*res = append(*res, fmt.Sprintf("f%d:%s", fIdx, t))
-		}
+		*res = append(*res, tokens...)
 	}
 	if c > 0 {
 		*flag = true
 	}
 }
 
+// 把字段内的查询串分词，生成索引中该字段的token
+func (idx *indexer) fieldTokens(fIdx int, q string) []string {
+	return fieldTokenKeys(fIdx, idx.tokenizeField(fIdx, q))
+}
+
+// 按字段的分析器对查询串分词，tokenizer为none的字段返回nil
+func (idx *indexer) tokenizeField(fIdx int, q string) []string {
+	if analyzer := fieldAnalyzer(idx.schema, fIdx); analyzer != nil {
+		return analyzer.Analyze(q)
+	}
+	return nil
+}
+
+// 不指定字段的查询串按每个分词的字符串字段的分析器分词，返回不重复的分词结果，在任一字段中匹配即可
+// 没有分词字段时按zh分词
+func (idx *indexer) analyzeAnyField(q string) [][]string {
+	var res [][]string
+	seen := map[string]bool{}
+	for fIdx := range idx.schema.Fields {
+		field := &idx.schema.Fields[fIdx]
+		if field.Type != conf.StringStrType && field.Type != conf.StringType {
+			continue
+		}
+		analyzer := fieldAnalyzer(idx.schema, fIdx)
+		if analyzer == nil {
+			continue
+		}
+		tokens := analyzer.Analyze(q)
+		key := strings.Join(tokens, "\x00")
+		if len(tokens) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, tokens)
+	}
+	if len(res) == 0 {
+		if tokens := zhTokenize(normalizeText(idx.schema, q)); len(tokens) > 0 {
+			res = append(res, tokens)
+		}
+	}
+	return res
+}
+
+func fieldTokenKeys(fIdx int, tokens []string) []string {
+	res := make([]string, len(tokens))
+	for i, t := range tokens {
+		res[i] = fmt.Sprintf("f%d:%s", fIdx, t)
+	}
+	return res
+}
+
+// 确定q语法树中每个查询词的字段和token，前缀、通配符、模糊查询及有同义词的查询词会被扩展，返回新的语法树
+func (idx *indexer) resolveQNode(node qNode) qNode {
+	switch n := node.(type) {
+	case *termNode:
+		if n.field != "" {
+			if fIdx, ok := idx.schema.FieldMap[n.field]; ok {
+				n.fIdx = fIdx
+			} else {
+				// 不是字段名，整个作为查询词
+				n.text = fmt.Sprintf("%s:%s", n.field, n.text)
+				n.field = ""
+			}
+		}
+		switch n.kind {
+		case termPrefix, termWildcard, termFuzzy:
+			n.text = normalizeText(idx.schema, n.text)
+			return idx.expandTerm(n)
+		case termExpanded:
+			return n
+		}
+		if synonyms := idx.synonymsOf(n.text); synonyms != nil {
+			return idx.expandSynonyms(n, synonyms)
+		}
+		return idx.tokenizeTerm(n)
+	case *boolNode:
+		for _, nodes := range [][]qNode{n.must, n.should, n.notIn} {
+			for i, c := range nodes {
+				nodes[i] = idx.resolveQNode(c)
+			}
+		}
+		return n
+	default:
+		return node
+	}
+}
+
+// 查询词分词，不指定字段的查询词在各字段的分词结果不同时扩展为多个可以出现的查询词
+func (idx *indexer) tokenizeTerm(t *termNode) qNode {
+	if t.fIdx >= 0 {
+		t.words = idx.tokenizeField(t.fIdx, t.text)
+		t.tokens = fieldTokenKeys(t.fIdx, t.words)
+		return t
+	}
+
+	variants := idx.analyzeAnyField(t.text)
+	if len(variants) <= 1 {
+		if len(variants) == 1 {
+			t.words, t.tokens = variants[0], variants[0]
+		}
+		return t
+	}
+	res := &boolNode{should: make([]qNode, len(variants))}
+	for i, words := range variants {
+		e := *t
+		e.words, e.tokens = words, words
+		res.should[i] = &e
+	}
+	return res
+}
+
+// 把有同义词的查询词扩展为多个可以出现的查询词，多个词组成的同义词按短语查询
+func (idx *indexer) expandSynonyms(t *termNode, synonyms []string) qNode {
+	res := &boolNode{should: make([]qNode, len(synonyms))}
+	for i, s := range synonyms {
+		e := &termNode{field: t.field, text: s, fIdx: t.fIdx, phrase: t.phrase, slop: t.slop}
+		if strings.Contains(s, " ") {
+			e.phrase = true
+		}
+		res.should[i] = idx.tokenizeTerm(e)
+	}
+	if len(res.should) == 1 {
+		return res.should[0]
+	}
+	return res
+}
+
+// 用词典中的token把前缀、通配符、模糊查询词扩展为多个可以出现的查询词
+func (idx *indexer) expandTerm(t *termNode) qNode {
+	idx.loadTermDict()
+	terms := idx.dict.expand(t.fIdx, t)
+	if len(terms) == 0 {
+		// 索引中不会有这个token，不会匹配任何doc
+		t.kind = termExpanded
+		t.words = []string{t.text}
+		if t.fIdx < 0 {
+			t.tokens = t.words
+		} else {
+			t.tokens = fieldTokenKeys(t.fIdx, t.words)
+		}
+		return t
+	}
+
+	res := &boolNode{should: make([]qNode, len(terms))}
+	for i, term := range terms {
+		e := &termNode{field: t.field, text: term, kind: termExpanded, fIdx: t.fIdx, words: []string{term}}
+		if t.fIdx < 0 {
+			e.tokens = e.words
+		} else {
+			e.tokens = fieldTokenKeys(t.fIdx, e.words)
+		}
+		res.should[i] = e
+	}
+	return res
+}
+
+// 根据q的语法树生成搜索引擎的检索条件，检索结果是语法树匹配结果的超集，打分时再精确匹配
+func generateExprTokens(expr qNode, logic *types.Logic) {
+	if must := requiredTokens(expr); len(must) > 0 {
+		logic.Must = true
+		logic.Expr.Must = append(logic.Expr.Must, must...)
+	} else if should, ok := anyTokens(expr); ok {
+		logic.Should = true
+		logic.Expr.Should = append(logic.Expr.Should, should...)
+	}
+	if notIn := excludedTokens(expr); len(notIn) > 0 {
+		logic.NotIn = true
+		logic.Expr.NotIn = append(logic.Expr.NotIn, notIn...)
+	}
+}
+
 func checkSortings(pqSortBys *[]sorting, fm map[string]int) {
 	sortBys := *pqSortBys
 	if len(sortBys) == 0 {
@@ -188,7 +418,7 @@ func checkSortings(pqSortBys *[]sorting, fm map[string]int) {
 	}
 }
 
-func makeDefaultSortBys(schema *conf.Schema) []sorting {
+func makeDefaultSortBys(schema *indexSchema) []sorting {
 	if schema.DefSortBys != nil && len(schema.DefSortBys) > 0 {
 		sortBys := make([]sorting, len(schema.DefSortBys))
 		for i, sortBy := range schema.DefSortBys {
@@ -212,7 +442,7 @@ func makeDefaultSortBys(schema *conf.Schema) []sorting {
 	return sortBys
 }
 
-func checkFilters(pqFilters *[]filter, schema *conf.Schema) {
+func checkFilters(pqFilters *[]filter, schema *indexSchema) {
 	filters := *pqFilters
 	if len(filters) == 0 {
 		*pqFilters = nil
@@ -330,25 +560,91 @@ func checkFilterRanges(field *conf.Field, franges *[]scope) {
 	}
 }
 
+// 在索引中检索语法树中每个查询词匹配的doc，短语按token的位置判断，打分时不需要再对doc分词
+func (idx *indexer) lookupQNode(node qNode, cache map[string]map[string]bool) {
+	switch n := node.(type) {
+	case *termNode:
+		if len(n.tokens) == 0 {
+			return
+		}
+		if !n.phrase || len(n.words) < 2 {
+			n.docs = idx.lookupTokens(n.tokens, false, 0, cache)
+			return
+		}
+		if n.fIdx >= 0 {
+			n.docs = idx.lookupTokens(n.tokens, true, n.slop, cache)
+			return
+		}
+		// 不指定字段的短语在任一字段中依次出现即可
+		n.docs = map[string]bool{}
+		for fIdx := range idx.schema.Fields {
+			field := &idx.schema.Fields[fIdx]
+			if (field.Type != conf.StringStrType && field.Type != conf.StringType) || fieldAnalyzer(idx.schema, fIdx) == nil {
+				continue
+			}
+			for docId := range idx.lookupTokens(fieldTokenKeys(fIdx, n.words), true, n.slop, cache) {
+				n.docs[docId] = true
+			}
+		}
+	case *boolNode:
+		for _, nodes := range [][]qNode{n.must, n.should, n.notIn} {
+			for _, c := range nodes {
+				idx.lookupQNode(c, cache)
+			}
+		}
+	}
+}
+
+// 检索同时出现所有tokens的doc，phrase为true时tokens还必须依次出现，间隔的token数之和不超过slop
+func (idx *indexer) lookupTokens(tokens []string, phrase bool, slop int, cache map[string]map[string]bool) map[string]bool {
+	key := fmt.Sprintf("%v:%d:%s", phrase, slop, strings.Join(tokens, "\x00"))
+	if docs, ok := cache[key]; ok {
+		return docs
+	}
+
+	collector := &tokenCollector{phrase: phrase, slop: slop, docs: map[string]bool{}}
+	idx.engine.Search(types.SearchReq{
+		Tokens: tokens,
+		RankOpts: &types.RankOpts{
+			ScoringCriteria: collector,
+		},
+	})
+	cache[key] = collector.docs
+	return collector.docs
+}
+
+// 收集检索到的doc，必须实现types.ScoringCriteria。打分函数会在多个shard中并发调用
+type tokenCollector struct {
+	phrase bool
+	slop   int
+	lock   sync.Mutex
+	docs   map[string]bool
+}
+
+func (c *tokenCollector) Score(doc types.IndexedDoc, fields interface{}) []float32 {
+	// doc.TokenLocs与检索的tokens一一对应
+	if !c.phrase || matchPhraseLocs(doc.TokenLocs, c.slop) {
+		c.lock.Lock()
+		c.docs[doc.DocId] = true
+		c.lock.Unlock()
+	}
+	return []float32{}
+}
+
 // 打分需要的数据，必须实现types.ScoringCriteria
 type scorerT struct {
-	schema *conf.Schema
+	schema *indexSchema
 	pq     *parsedQuery
-}
+	facets *facetCounter // 统计所有匹配doc的facet，不需要时为nil
+	aggs   *aggregator   // 统计所有匹配doc的聚合，不需要时为nil
 
-const (
-	// 最大临近距离
-	MaxTokenProximity = 2
-)
+	total   int64        // 游标分页时所有匹配的doc数，包括游标之前的doc
+	count   *int64       // 计数时累加匹配的doc数，不需要打分
+	matched *matchedDocs // 按条件删除、更新及导出时收集匹配的doc，不需要打分
+}
 
 // 打分函数，是types.ScoringCriteria接口定义的函数
 func (scorer *scorerT) Score(doc types.IndexedDoc, fields interface{}) []float32 {
-	// 评分第一步
-	// fmt.Printf("doc.TokenProximity: %d\n", doc.TokenProximity)
-	if doc.TokenProximity > MaxTokenProximity {
-		return []float32{}
-	}
-
 	if reflect.TypeOf(fields) != reflect.TypeOf(StoredDoc{}) {
 		return []float32{}
 	}
@@ -359,61 +655,50 @@ func (scorer *scorerT) Score(doc types.IndexedDoc, fields interface{}) []float32
 		return []float32{}
 	}
 
-	// fmt.Printf("doc.BM25: %v\n", doc.BM25)
-	/*
-		if scorer.pq.sortBys == nil {
-			// fmt.Printf("doc.BM25: %v\n", doc.BM25)
-			return []float32{float32(int(doc.BM25))}
-		}*/
+	// 精确匹配q的语法树，查询词匹配的doc在检索前已经确定
+	if scorer.pq.expr != nil && !scorer.pq.expr.match(&matchingDoc{id: doc.DocId, doc: storedDoc, schema: scorer.schema}) {
+		return []float32{}
+	}
 
-	return storedDoc.score(scorer.pq.sortBys, int(doc.BM25))
-}
+	if scorer.count != nil {
+		atomic.AddInt64(scorer.count, 1)
+		return []float32{}
+	}
+	if scorer.matched != nil {
+		scorer.matched.add(doc.DocId, storedDoc)
+		return []float32{}
+	}
 
-func (d StoredDoc) score(sortBys []sorting, bm25 int) []float32 {
-	output := make([]float32, len(sortBys))
-	for i, sortBy := range sortBys {
-		// fIdx := sortBy.fIdx
-		storedVal, ok := d[sortBy.fieldName]
-		if !ok || storedVal == nil {
-			output[i] = 0
-			continue
-		}
+	if scorer.facets != nil {
+		scorer.facets.add(storedDoc)
+	}
+	if scorer.aggs != nil {
+		scorer.aggs.add(storedDoc)
+	}
 
-		output[i] = sortingScore(storedVal, bm25)
-		if sortBy.asc {
-			if output[i] != 0 {
-				output[i] = float32(1.0) / output[i]
-			} else {
-				output[i] = math.MaxFloat32
-			}
+	// total、facet、聚合统计所有匹配的doc，翻页时不变；游标之前的doc已经输出过了，不再打分
+	if scorer.pq.cursor != nil {
+		atomic.AddInt64(&scorer.total, 1)
+		if !scorer.pq.cursor.before(storedDoc, doc.DocId, scorer.pq.sortBys, scorer.schema) {
+			return []float32{}
 		}
 	}
-	return output
-}
 
-func sortingScore(storedVal interface{}, bm25 int) float32 {
-	v := reflect.ValueOf(storedVal)
+	// fmt.Printf("doc.BM25: %v\n", doc.BM25)
+	/*
+		if scorer.pq.sortBys == nil {
+			// fmt.Printf("doc.BM25: %v\n", doc.BM25)
+			return []float32{float32(int(doc.BM25))}
+		}*/
 
-	switch i := storedVal.(type) {
-	case string:
-		return float32(bm25)
-	case int8, int16, int32, int64, int:
-		return float32(v.Int())
-	case uint8, uint16, uint32, uint64, uint:
-		return float32(v.Uint())
-	case float32, float64:
-		return float32(v.Float())
-	case bool:
-		if i {
-			return float32(2)
-		}
-		return float32(1)
-	default:
-		return float32(0)
+	scores := storedDoc.score(scorer.pq.sortBys)
+	if scorer.pq.cursor != nil {
+		scores = append(scores, docIdScore(doc.DocId)...)
 	}
+	return scores
 }
 
-func (d StoredDoc) satisfied(filters []filter, schema *conf.Schema) bool {
+func (d StoredDoc) satisfied(filters []filter, schema *indexSchema) bool {
 	if filters == nil {
 		return true
 	}
@@ -427,9 +712,8 @@ func (d StoredDoc) satisfied(filters []filter, schema *conf.Schema) bool {
 
 		if f.conds != nil {
 			found := false
-			tokenizer := schema.Fields[f.fIdx].Tokenizer
 			for _, cond := range f.conds {
-				if condEquals(storedVal, cond, tokenizer) {
+				if condEquals(storedVal, cond, schema, f.fIdx) {
 					found = true
 					break
 				}
@@ -456,20 +740,27 @@ func (d StoredDoc) satisfied(filters []filter, schema *conf.Schema) bool {
 	return true
 }
 
-func condEquals(storedVal, cond interface{}, tokenizer string) bool {
+// 字符串字段: zh分词的字段包含条件串，不分词的字段等于条件串，其它字段的某个token等于条件串
+// 条件串与字段值用同一个分析器处理，比较前都按schema的normalize规范化
+func condEquals(storedVal, cond interface{}, schema *indexSchema, fIdx int) bool {
 	switch cond.(type) {
 	case string:
 		cv, _ := cond.(string)
 		sv, _ := storedVal.(string)
-		switch tokenizer {
-		case conf.ZhTokenizer:
-			return strings.Contains(sv, cv)
-		case conf.NoneTokenizer:
-			return cv == strings.TrimSpace(sv)
+		analyzer := fieldAnalyzer(schema, fIdx)
+		switch {
+		case analyzer == nil:
+			return normalizeText(schema, cv) == normalizeText(schema, strings.TrimSpace(sv))
+		case schema.Fields[fIdx].AnalyzerName() == conf.ZhTokenizer:
+			return strings.Contains(normalizeText(schema, sv), normalizeText(schema, cv))
 		default:
-			tokens := whitespaceTokenize(sv)
-			for _, token := range tokens {
-				if cv == token {
+			// 条件串分析后只能是一个token
+			cTokens := analyzer.Analyze(cv)
+			if len(cTokens) != 1 {
+				return false
+			}
+			for _, token := range analyzer.Analyze(sv) {
+				if token == cTokens[0] {
 					return true
 				}
 			}
@@ -550,16 +841,18 @@ func (idx *indexer) outputResult(
 	pq *parsedQuery,
 ) (pagination interface{}, timeout bool, docsCh chan interface{}) {
 	p := struct {
-		Total     int `json:"total"`
-		Pages     int `json:"pages"`
-		PageSize  int `json:"page-size"`
-		CurrPage  int `json:"curr-page"`
-		PageCount int `json:"page-count"`
+		Total     int    `json:"total"`
+		Pages     int    `json:"pages"`
+		PageSize  int    `json:"page-size"`
+		CurrPage  int    `json:"curr-page"`
+		PageCount int    `json:"page-count"`
+		After     string `json:"after,omitempty"` // 下一页的游标
 	}{
 		Total:    searchResp.NumDocs,
 		Pages:    (searchResp.NumDocs + pq.rows - 1) / pq.rows,
 		CurrPage: pq.start/pq.rows + 1,
 		PageSize: pq.rows,
+		After:    pq.nextCursor,
 	}
 	timeout = searchResp.Timeout
 	pagination = &p
@@ -580,41 +873,35 @@ func (idx *indexer) outputResult(
 	docsCh = make(chan interface{})
 
 	go func() {
-		var retDoc StoredDoc
-
-		for _, doc := range docs {
+		for i := range docs {
+			doc := &docs[i]
 			storedDoc, ok := doc.Fields.(StoredDoc)
 			if !ok {
 				continue
 			}
 
-			outFieldList := pq.outFieldList
-			if outFieldList == nil {
-				if schema.TimeIdx == nil {
-					retDoc = storedDoc
-				} else {
-					retDoc = StoredDoc{}
-					for k, v := range storedDoc {
-						if fIdx, ok := schema.TimeIdx[k]; !ok {
-							retDoc[k] = v
-						} else {
-							field := &schema.Fields[fIdx]
-							retDoc[k] = field.FormatDatetime(v)
-						}
-					}
+			retDoc := idx.outputDoc(storedDoc, pq.outFieldList)
+
+			var hlRes map[string][]string
+			if pq.hl != nil {
+				hlRes = pq.hl.doc(storedDoc, schema)
+			}
+			var exp *Explanation
+			if pq.explain {
+				exp = explainDoc(doc, storedDoc, pq, schema)
+			}
+			if hlRes != nil || exp != nil {
+				extDoc := make(StoredDoc, len(retDoc)+2)
+				for k, v := range retDoc {
+					extDoc[k] = v
 				}
-			} else {
-				retDoc = StoredDoc{}
-				for _, f := range outFieldList {
-					if v, ok := storedDoc[f]; ok {
-						if fIdx, ok := schema.TimeIdx[f]; !ok {
-							retDoc[f] = v
-						} else {
-							field := &schema.Fields[fIdx]
-							retDoc[f] = field.FormatDatetime(v)
-						}
-					}
+				if hlRes != nil {
+					extDoc["_highlight"] = hlRes
 				}
+				if exp != nil {
+					extDoc["_explain"] = exp
+				}
+				retDoc = extDoc
 			}
 
 			docsCh <- retDoc
@@ -625,3 +912,40 @@ func (idx *indexer) outputResult(
 
 	return
 }
+
+// 生成输出的doc，只输出outFieldList中的字段，时间字段格式化输出
+func (idx *indexer) outputDoc(storedDoc StoredDoc, outFieldList []string) StoredDoc {
+	schema := idx.schema
+	var retDoc StoredDoc
+	if outFieldList == nil {
+		if schema.TimeIdx == nil {
+			retDoc = storedDoc
+		} else {
+			retDoc = StoredDoc{}
+			for k, v := range storedDoc {
+				if fIdx, ok := schema.TimeIdx[k]; !ok {
+					retDoc[k] = v
+				} else {
+					field := &schema.Fields[fIdx]
+					retDoc[k] = field.FormatDatetime(v)
+				}
+			}
+		}
+	} else {
+		retDoc = StoredDoc{}
+		for _, f := range outFieldList {
+			if v, ok := storedDoc[f]; ok {
+				if fIdx, ok := schema.TimeIdx[f]; !ok {
+					retDoc[f] = v
+				} else {
+					field := &schema.Fields[fIdx]
+					retDoc[f] = field.FormatDatetime(v)
+				}
+			}
+		}
+		if v, ok := storedDoc[VersionField]; ok {
+			retDoc[VersionField] = v
+		}
+	}
+	return retDoc
+}
@@ -0,0 +1,624 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// q的查询语法:
+//   expr   := and { ("OR"|"||") and }
+//   and    := seq { ("AND"|"&&") seq }
+//   seq    := unary { unary }                  // 相邻的子句: +必出现、-必不出现、其它可以出现
+//   unary  := ("+"|"-"|"!"|"NOT") unary | primary
+//   primary:= "(" expr ")" | field ":" "(" expr ")" | [field ":"] term
+//   term   := word | prefix "*" | wildcard | word "~" [distance] | '"' phrase '"' ["~" slop]
+//
+// 加引号的查询词是短语，短语分词后的token必须在同一字段中依次出现，
+// 相邻token间缺省不能有间隔，"~slop"指定所有间隔token数之和的最大值。
+// 没有引号时，"xxx*"是前缀查询，含有'*'/'?'的是通配符查询，"xxx~n"是编辑距离不超过n的模糊查询，
+// 它们都会用词典中匹配的token扩展为多个可以出现的查询词。
+// 没有括号和AND/OR/NOT时，语法与原来的"+must should -notIn"完全一致
+
+// q语法树的节点
+type qNode interface {
+	match(d *matchingDoc) bool
+}
+
+// 打分时精确匹配q语法树的doc
+type matchingDoc struct {
+	id     string
+	doc    StoredDoc
+	schema *indexSchema
+}
+
+// 单个查询词
+type termNode struct {
+	field  string          // 字段名，为空表示在所有字段中查询
+	text   string          // 查询词，短语不包含引号
+	phrase bool            // 是否是短语
+	slop   int             // 短语中token间允许的间隔
+	kind   int             // 精确、前缀、通配符、模糊查询
+	fuzzy  int             // 模糊查询的编辑距离
+	fIdx   int             // set when querying, -1表示不限字段
+	words  []string        // set when querying, 分词结果
+	tokens []string        // set when querying, 索引中对应的token
+	docs   map[string]bool // set when querying, 在索引中检索到的匹配doc
+}
+
+// 布尔组合: must全部出现，notIn都不出现，如果有should，至少出现一个
+type boolNode struct {
+	must   []qNode
+	should []qNode
+	notIn  []qNode
+}
+
+const (
+	termExact = iota
+	termPrefix
+	termWildcard
+	termFuzzy
+	termExpanded // 扩展出来的查询词，token已经确定
+)
+
+const (
+	modShould = iota
+	modMust
+	modNotIn
+)
+
+const (
+	lexTerm = iota
+	lexLParen
+	lexRParen
+	lexAnd
+	lexOr
+	lexNot
+	lexMust
+	lexField // "field:"后面紧跟着'('
+)
+
+type lexToken struct {
+	kind     int
+	text     string
+	field    string
+	phrase   bool
+	slop     int
+	termKind int
+	fuzzy    int
+}
+
+// 解析q参数，返回语法树。q为空时返回nil
+func parseQExpr(q string) (qNode, error) {
+	tokens := lexQ(q)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &qParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected \"%s\" in q", p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+func lexQ(q string) []lexToken {
+	rs := []rune(q)
+	n := len(rs)
+	res := []lexToken{}
+
+	isBound := func(i int) bool {
+		return i >= n || unicode.IsSpace(rs[i]) || rs[i] == '(' || rs[i] == ')'
+	}
+
+	for i := 0; i < n; {
+		ch := rs[i]
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '(':
+			res = append(res, lexToken{kind: lexLParen, text: "("})
+			i++
+		case ch == ')':
+			res = append(res, lexToken{kind: lexRParen, text: ")"})
+			i++
+		case ch == '+' || ch == '-' || ch == '!':
+			i++
+			if isBound(i) && (i >= n || rs[i] != '(') {
+				// 单独的'+'/'-'，忽略
+				continue
+			}
+			if ch == '+' {
+				res = append(res, lexToken{kind: lexMust, text: "+"})
+			} else {
+				res = append(res, lexToken{kind: lexNot, text: string(ch)})
+			}
+		case ch == '&' && i+1 < n && rs[i+1] == '&':
+			res = append(res, lexToken{kind: lexAnd, text: "&&"})
+			i += 2
+		case ch == '|' && i+1 < n && rs[i+1] == '|':
+			res = append(res, lexToken{kind: lexOr, text: "||"})
+			i += 2
+		default:
+			start := i
+			var quote rune
+			colon := -1
+			for ; i < n; i++ {
+				c := rs[i]
+				if quote != 0 {
+					if c == quote {
+						quote = 0
+					}
+					continue
+				}
+				if c == '"' || c == '\'' || c == '`' {
+					quote = c
+					continue
+				}
+				if c == ':' && colon < 0 && i > start {
+					colon = i
+				}
+				if unicode.IsSpace(c) || c == '(' || c == ')' {
+					break
+				}
+			}
+			word := string(rs[start:i])
+			switch word {
+			case "AND":
+				res = append(res, lexToken{kind: lexAnd, text: word})
+				continue
+			case "OR":
+				res = append(res, lexToken{kind: lexOr, text: word})
+				continue
+			case "NOT":
+				res = append(res, lexToken{kind: lexNot, text: word})
+				continue
+			}
+			if colon < 0 {
+				res = append(res, newTermToken("", word))
+				continue
+			}
+			field := string(rs[start:colon])
+			text := string(rs[colon+1 : i])
+			if text == "" {
+				if i < n && rs[i] == '(' {
+					res = append(res, lexToken{kind: lexField, text: word, field: field})
+				} else {
+					res = append(res, lexToken{kind: lexTerm, text: word})
+				}
+				continue
+			}
+			res = append(res, newTermToken(field, text))
+		}
+	}
+	return res
+}
+
+// 生成查询词，整个被引号括起来的是短语，后面可以跟"~slop"
+func newTermToken(field, text string) lexToken {
+	token := lexToken{kind: lexTerm, text: text, field: field}
+
+	phrase := text
+	slop := 0
+	if pos := strings.LastIndexByte(text, '~'); pos > 0 {
+		if n, err := strconv.Atoi(text[pos+1:]); err == nil && n >= 0 {
+			phrase, slop = text[:pos], n
+		}
+	}
+	if len(phrase) < 2 {
+		return newPatternToken(token)
+	}
+	switch phrase[0] {
+	case '"', '\'', '`':
+		if phrase[len(phrase)-1] != phrase[0] || strings.IndexByte(phrase[1:len(phrase)-1], phrase[0]) >= 0 {
+			return token
+		}
+		token.text = phrase[1 : len(phrase)-1]
+		token.phrase = true
+		token.slop = slop
+		return token
+	}
+	return newPatternToken(token)
+}
+
+// 判断是否是前缀、通配符或模糊查询
+func newPatternToken(token lexToken) lexToken {
+	text := token.text
+	if strings.ContainsAny(text, "\"'`") {
+		return token
+	}
+
+	if pos := strings.LastIndexByte(text, '~'); pos > 0 && !strings.ContainsAny(text[:pos], "*?") {
+		dist := MaxFuzzyDistance
+		if pos+1 < len(text) {
+			n, err := strconv.Atoi(text[pos+1:])
+			if err != nil || n < 0 {
+				return token
+			}
+			dist = minInt(n, MaxFuzzyDistance)
+		}
+		token.text = text[:pos]
+		token.termKind = termFuzzy
+		token.fuzzy = dist
+		return token
+	}
+
+	wildcards := strings.Count(text, "*") + strings.Count(text, "?")
+	switch {
+	case wildcards == 0 || wildcards == len(text):
+	case wildcards == 1 && text[len(text)-1] == '*':
+		token.termKind = termPrefix
+	default:
+		token.termKind = termWildcard
+	}
+	return token
+}
+
+type qParser struct {
+	tokens []lexToken
+	pos    int
+}
+
+func (p *qParser) peek() int {
+	if p.pos >= len(p.tokens) {
+		return -1
+	}
+	return p.tokens[p.pos].kind
+}
+
+func (p *qParser) parseOr() (qNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []qNode{node}
+	for p.peek() == lexOr {
+		p.pos++
+		if node, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &boolNode{should: nodes}, nil
+}
+
+func (p *qParser) parseAnd() (qNode, error) {
+	node, err := p.parseSeq()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []qNode{node}
+	for p.peek() == lexAnd {
+		p.pos++
+		if node, err = p.parseSeq(); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return &boolNode{must: nodes}, nil
+}
+
+func (p *qParser) parseSeq() (qNode, error) {
+	res := &boolNode{}
+	count := 0
+	for {
+		switch p.peek() {
+		case lexTerm, lexLParen, lexMust, lexNot, lexField:
+		default:
+			if count == 0 {
+				if p.pos >= len(p.tokens) {
+					return nil, fmt.Errorf("unexpected end of q")
+				}
+				return nil, fmt.Errorf("unexpected \"%s\" in q", p.tokens[p.pos].text)
+			}
+			if count == 1 && len(res.should) == 1 {
+				return res.should[0], nil
+			}
+			return res, nil
+		}
+
+		node, mod, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		switch mod {
+		case modMust:
+			res.must = append(res.must, node)
+		case modNotIn:
+			res.notIn = append(res.notIn, node)
+		default:
+			res.should = append(res.should, node)
+		}
+		count++
+	}
+}
+
+func (p *qParser) parseUnary() (qNode, int, error) {
+	var mod int
+	switch p.peek() {
+	case lexMust:
+		mod = modMust
+	case lexNot:
+		mod = modNotIn
+	default:
+		node, err := p.parsePrimary()
+		return node, modShould, err
+	}
+
+	p.pos++
+	node, innerMod, err := p.parseUnary()
+	if err != nil {
+		return nil, 0, err
+	}
+	if innerMod == modNotIn {
+		node = &boolNode{notIn: []qNode{node}}
+	}
+	return node, mod, nil
+}
+
+func (p *qParser) parsePrimary() (qNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of q")
+	}
+	token := &p.tokens[p.pos]
+	p.pos++
+
+	switch token.kind {
+	case lexTerm:
+		return &termNode{
+			field:  token.field,
+			text:   token.text,
+			phrase: token.phrase,
+			slop:   token.slop,
+			kind:   token.termKind,
+			fuzzy:  token.fuzzy,
+			fIdx:   -1,
+		}, nil
+	case lexLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != lexRParen {
+			return nil, fmt.Errorf("')' expected in q")
+		}
+		p.pos++
+		return node, nil
+	case lexField:
+		node, err := p.parsePrimary() // must be '('
+		if err != nil {
+			return nil, err
+		}
+		setDefaultField(node, token.field)
+		return node, nil
+	default:
+		return nil, fmt.Errorf("unexpected \"%s\" in q", token.text)
+	}
+}
+
+// 给没有指定字段的查询词加上字段名
+func setDefaultField(node qNode, field string) {
+	switch n := node.(type) {
+	case *termNode:
+		if n.field == "" {
+			n.field = field
+		}
+	case *boolNode:
+		for _, nodes := range [][]qNode{n.must, n.should, n.notIn} {
+			for _, c := range nodes {
+				setDefaultField(c, field)
+			}
+		}
+	}
+}
+
+// 把两个语法树合并为同一层的子句，与q、fq的token合并在一起检索的语义一致
+func mergeQNode(a, b qNode) qNode {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	ba, bb := asBoolNode(a), asBoolNode(b)
+	return &boolNode{
+		must:   append(append([]qNode{}, ba.must...), bb.must...),
+		should: append(append([]qNode{}, ba.should...), bb.should...),
+		notIn:  append(append([]qNode{}, ba.notIn...), bb.notIn...),
+	}
+}
+
+func asBoolNode(node qNode) *boolNode {
+	if b, ok := node.(*boolNode); ok {
+		return b
+	}
+	return &boolNode{should: []qNode{node}}
+}
+
+// 如果语法树只是一层的"+must should -notIn"，转换为原来的query和fquery
+func flattenQNode(node qNode, fields []conf.Field) (*query, []fquery, bool) {
+	var b *boolNode
+	switch n := node.(type) {
+	case *termNode:
+		b = &boolNode{should: []qNode{n}}
+	case *boolNode:
+		b = n
+	default:
+		return nil, nil, false
+	}
+
+	q := &query{should: []string{}, must: []string{}, notIn: []string{}}
+	fqm := map[int]*query{}
+	var fqs []fquery
+	for mod, nodes := range [][]qNode{b.should, b.must, b.notIn} {
+		for _, c := range nodes {
+			t, ok := c.(*termNode)
+			if !ok || t.phrase || t.kind != termExact {
+				return nil, nil, false
+			}
+			tq := q
+			if t.fIdx >= 0 {
+				if tq, ok = fqm[t.fIdx]; !ok {
+					tq = &query{should: []string{}, must: []string{}, notIn: []string{}}
+					fqm[t.fIdx] = tq
+					fqs = append(fqs, fquery{fieldName: fields[t.fIdx].Name, query: tq})
+				}
+			}
+			switch mod {
+			case modShould:
+				tq.should = append(tq.should, t.text)
+			case modMust:
+				tq.must = append(tq.must, t.text)
+			default:
+				tq.notIn = append(tq.notIn, t.text)
+			}
+		}
+	}
+	return q, fqs, true
+}
+
+// 所有匹配的doc中都必须出现的token
+func requiredTokens(node qNode) []string {
+	switch n := node.(type) {
+	case *termNode:
+		return n.tokens
+	case *boolNode:
+		var res []string
+		for _, c := range n.must {
+			res = append(res, requiredTokens(c)...)
+		}
+		if len(n.should) == 1 {
+			res = append(res, requiredTokens(n.should[0])...)
+		}
+		return res
+	default:
+		return nil
+	}
+}
+
+// 所有匹配的doc中至少出现一个的token，如果无法确定返回false
+func anyTokens(node qNode) ([]string, bool) {
+	switch n := node.(type) {
+	case *termNode:
+		return n.tokens, len(n.tokens) > 0
+	case *boolNode:
+		for _, c := range n.must {
+			if res, ok := anyTokens(c); ok {
+				return res, true
+			}
+		}
+		if len(n.should) == 0 {
+			return nil, false
+		}
+		var res []string
+		for _, c := range n.should {
+			tokens, ok := anyTokens(c)
+			if !ok {
+				return nil, false
+			}
+			res = append(res, tokens...)
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+// 一定不会出现在匹配的doc中的token
+func excludedTokens(node qNode) []string {
+	n, ok := node.(*boolNode)
+	if !ok {
+		return nil
+	}
+	var res []string
+	for _, c := range n.notIn {
+		if t, ok := c.(*termNode); ok && len(t.tokens) == 1 {
+			res = append(res, t.tokens[0])
+		}
+	}
+	return res
+}
+
+func (t *termNode) match(d *matchingDoc) bool {
+	if len(t.tokens) == 0 {
+		if t.fIdx < 0 {
+			return false
+		}
+		// 不分词的字段直接比较
+		sv, ok := d.doc[d.schema.Fields[t.fIdx].Name].(string)
+		return ok && normalizeText(d.schema, strings.TrimSpace(sv)) == normalizeText(d.schema, unquote(t.text))
+	}
+	return t.docs[d.id]
+}
+
+// 判断短语的token是否依次出现，且所有间隔的token数之和不超过slop，locs是短语中每个token在doc中的位置
+func matchPhraseLocs(locs [][]int, slop int) bool {
+	if len(locs) == 0 {
+		return false
+	}
+	for _, loc := range locs[0] {
+		if matchPhraseFrom(locs[1:], loc+1, slop) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPhraseFrom(locs [][]int, start int, slop int) bool {
+	if len(locs) == 0 {
+		return true
+	}
+	for _, loc := range locs[0] {
+		if loc >= start && loc-start <= slop && matchPhraseFrom(locs[1:], loc+1, slop-(loc-start)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *boolNode) match(d *matchingDoc) bool {
+	for _, c := range b.must {
+		if !c.match(d) {
+			return false
+		}
+	}
+	for _, c := range b.notIn {
+		if c.match(d) {
+			return false
+		}
+	}
+	if len(b.should) == 0 {
+		return true
+	}
+	for _, c := range b.should {
+		if c.match(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		switch s[0] {
+		case '"', '\'', '`':
+			if s[len(s)-1] == s[0] {
+				return s[1 : len(s)-1]
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,250 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"strings"
+	"testing"
+)
+
+func dumpQNode(node qNode) string {
+	switch n := node.(type) {
+	case *termNode:
+		text := n.text
+		if n.phrase {
+			text = fmt.Sprintf("%q", text)
+			if n.slop > 0 {
+				text = fmt.Sprintf("%s~%d", text, n.slop)
+			}
+		}
+		if n.kind == termFuzzy {
+			text = fmt.Sprintf("%s~%d", text, n.fuzzy)
+		}
+		if n.field != "" {
+			return fmt.Sprintf("%s:%s", n.field, text)
+		}
+		return text
+	case *boolNode:
+		var items []string
+		for _, c := range n.must {
+			items = append(items, "+"+dumpQNode(c))
+		}
+		for _, c := range n.should {
+			items = append(items, dumpQNode(c))
+		}
+		for _, c := range n.notIn {
+			items = append(items, "-"+dumpQNode(c))
+		}
+		return "(" + strings.Join(items, " ") + ")"
+	default:
+		return "?"
+	}
+}
+
+func Test_parseQExpr(t *testing.T) {
+	cases := map[string]string{
+		"hello":       "hello",
+		"+a b -c":     "(+a b -c)",
+		"a AND b":     "(+a +b)",
+		"a OR b && c": "(a (+b +c))",
+		"(red OR blue) AND -(used) AND brand:acme": "(+(red blue) +(-used) +brand:acme)",
+		"NOT a":                          "(-a)",
+		"brand:(acme OR foo) bar":        "((brand:acme brand:foo) bar)",
+		`"hello world" x:"y z"`:          `("hello world" x:"y z")`,
+		"2019-10-14 + -":                 "2019-10-14",
+		`'a b'~3 name:"c"~1`:             `("a b"~3 name:"c"~1)`,
+		`ab"c d" x~2`:                    `(ab"c d" x~2)`,
+		"prod* c?l*r sku:ab* colour~1 ~": "(prod* c?l*r sku:ab* colour~1 ~)",
+	}
+	for q, expected := range cases {
+		node, err := parseQExpr(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		if s := dumpQNode(node); s != expected {
+			t.Errorf("%s: expected %s, got %s", q, expected, s)
+		}
+	}
+
+	for _, q := range []string{"(a OR b", "a AND", "OR a", "a )"} {
+		if _, err := parseQExpr(q); err == nil {
+			t.Errorf("%s: error expected", q)
+		}
+	}
+
+	if node, err := parseQExpr("  "); node != nil || err != nil {
+		t.Errorf("empty q: nil expected")
+	}
+}
+
+func testSchema() *indexSchema {
+	fields := []conf.Field{
+		{Name: "id", PK: true, Type: "u32"},
+		{Name: "name", Type: "str", Tokenizer: conf.WsTokenizer},
+		{Name: "brand", Type: "str", Tokenizer: conf.NoneTokenizer},
+		{Name: "title", Type: "str", Tokenizer: conf.ZhTokenizer},
+	}
+	fm := map[string]int{}
+	for i, f := range fields {
+		fm[f.Name] = i
+	}
+	return &indexSchema{Schema: &conf.Schema{
+		Name:       "test",
+		SchemaConf: &conf.SchemaConf{Fields: fields},
+		FieldMap:   fm,
+		PKIdx:      []int{0},
+	}}
+}
+
+const qNodeSchemaJSON = `{"fields": [
+	{"name": "id", "type": "u32", "pk": true},
+	{"name": "name"},
+	{"name": "brand", "tokenizer": "none"},
+	{"name": "title", "tokenizer": "zh"}
+]}`
+
+// 在索引中检索q的查询词后判断doc是否匹配q的语法树
+func matchQ(t *testing.T, index string, q string, docId string) bool {
+	idx, err := initIndexer(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := parseQExpr(q)
+	if err != nil {
+		t.Fatalf("%s: %v", q, err)
+	}
+	node = idx.resolveQNode(node)
+	idx.lookupQNode(node, map[string]map[string]bool{})
+	docs, err := GetDocs(index, []string{docId}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node.match(&matchingDoc{id: docId, doc: docs[docId], schema: idx.schema})
+}
+
+func Test_qNodeMatch(t *testing.T) {
+	index, teardown := setupTestIndex(t, qNodeSchemaJSON, jsonDocs(`[
+		{"id": 1, "name": "red shoes", "brand": "acme", "title": "红色的鞋"},
+		{"id": 2, "name": "shoes red", "brand": "other", "title": "绿鞋"}
+	]`))
+	defer teardown()
+
+	cases := map[string]bool{
+		"red": true,
+		"(red OR blue) AND -(used) AND brand:acme": true,
+		"(red OR blue) AND brand:other":            false,
+		"+shoes -red":                              false,
+		"name:shoes title:鞋":                       true,
+		"title:(红 AND 绿)":                          false,
+		"NOT used":                                 true,
+		`"red shoes"`:                              true,
+		`"shoes red"`:                              false,
+		`name:"red shoes" AND brand:acme`:          true,
+		`title:"红色鞋"`:                              false,
+		`title:"红色鞋"~1`:                            true,
+		`title:"红鞋"~1`:                             false,
+	}
+	for q, expected := range cases {
+		if matchQ(t, index, q, "1") != expected {
+			t.Errorf("%s: expected %v", q, expected)
+		}
+	}
+	if !matchQ(t, index, `"shoes red"`, "2") || matchQ(t, index, `"red shoes"`, "2") {
+		t.Errorf("phrases should match doc 2 by token locations")
+	}
+}
+
+func Test_flattenQNode(t *testing.T) {
+	idx := &indexer{schema: testSchema()}
+	node, _ := parseQExpr("+red shoes -used name:big")
+	node = idx.resolveQNode(node)
+	q, fqs, ok := flattenQNode(node, idx.schema.Fields)
+	if !ok {
+		t.Fatalf("flat query expected")
+	}
+	if len(q.must) != 1 || len(q.should) != 1 || len(q.notIn) != 1 {
+		t.Errorf("unexpected query: %#v", q)
+	}
+	if len(fqs) != 1 || fqs[0].fieldName != "name" {
+		t.Errorf("unexpected fquerys: %#v", fqs)
+	}
+
+	node, _ = parseQExpr("(red OR blue) AND shoes")
+	node = idx.resolveQNode(node)
+	if _, _, ok = flattenQNode(node, idx.schema.Fields); ok {
+		t.Errorf("nested query should not be flattened")
+	}
+}
+
+func Test_termExpansion(t *testing.T) {
+	schema := testSchema()
+	idx := &indexer{schema: schema, dict: newTermDict()}
+	idx.dict.loadOnce.Do(func() {})
+	idx.dict.add(1, []string{"product", "production", "red", "color", "colour", "cooler"})
+	idx.dict.add(3, []string{"红", "色"})
+
+	cases := map[string][]string{
+		"prod*":        {"product", "production"},
+		"name:c?l*r":   {"f1:color", "f1:colour"},
+		"colour~1":     {"colour", "color"},
+		"name:colour~": {"f1:colour", "f1:color", "f1:cooler"},
+		"title:prod*":  {"f3:prod*"},
+		"nothing*":     {"nothing*"},
+	}
+	for q, expected := range cases {
+		node, err := parseQExpr(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		node = idx.resolveQNode(node)
+		var tokens []string
+		for _, c := range asBoolNode(node).should {
+			tokens = append(tokens, c.(*termNode).tokens...)
+		}
+		if fmt.Sprintf("%v", tokens) != fmt.Sprintf("%v", expected) {
+			t.Errorf("%s: expected %v, got %v", q, expected, tokens)
+		}
+	}
+
+	index, teardown := setupTestIndex(t, qNodeSchemaJSON, jsonDocs(`[
+		{"id": 1, "name": "red production", "brand": "acme", "title": "红色"},
+		{"id": 2, "name": "blue colour", "brand": "acme", "title": "蓝色"}
+	]`))
+	defer teardown()
+	for q, expected := range map[string]bool{"prod*": true, "+red +colour~1": false, "p?oduct*": true, "name:colur~1": false} {
+		if matchQ(t, index, q, "1") != expected {
+			t.Errorf("%s: expected %v", q, expected)
+		}
+	}
+	if !matchQ(t, index, "name:colur~1", "2") {
+		t.Errorf("name:colur~1 should match doc 2")
+	}
+}
+
+func Test_editDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		max  int
+		dist int
+	}{
+		{"colour", "color", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 2, 3},
+		{"红色", "红包", 1, 1},
+		{"", "ab", 2, 2},
+		{"shose", "shoes", 1, 1},
+	}
+	for _, c := range cases {
+		if d := editDistance([]rune(c.a), []rune(c.b), c.max); d != c.dist {
+			t.Errorf("%s/%s: expected %d, got %d", c.a, c.b, c.dist, d)
+		}
+	}
+
+	for pattern, expected := range map[string]bool{"c?l*r": true, "*our": true, "c*l": false, "colou?": true, "?": false} {
+		if wildcardMatch([]rune(pattern), []rune("colour")) != expected {
+			t.Errorf("%s: expected %v", pattern, expected)
+		}
+	}
+}
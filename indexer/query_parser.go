@@ -6,28 +6,60 @@ import (
 	"strings"
 )
 
-// 把输入的query参数进行解析，这一步和具体的搜索引擎没有关系
-func parseQuery(q, fq, s, f, page, pagesize, fl string) (*parsedQuery, error) {
-	var qLabels []string
-	qRes, err := parseQ(q)
+// 把输入的query参数进行解析，这一步和具体的搜索引擎没有关系，参数不合法时返回badRequestError
+func parseQuery(args *QueryArgs) (*parsedQuery, error) {
+	qExpr, err := parseQExpr(args.Q)
 	if err != nil {
-		qLabels = allDocs
+		return nil, badRequestError{err}
 	}
-	fqRes, err := parseFq(fq)
+	fqRes, err := parseFq(args.Fq)
 	if err != nil {
-		return nil, err
+		return nil, badRequestError{err}
 	}
-	fRes, err := parseF(f)
+	fRes, err := parseF(args.F)
 	if err != nil {
-		return nil, err
+		return nil, badRequestError{err}
 	}
 
-	sRes := parseS(s)
-	flRes := parseFl(fl)
+	sRes, err := parseS(args.S)
+	if err != nil {
+		return nil, badRequestError{err}
+	}
+	flRes := parseFl(args.Fl)
+	facetRes := parseFl(args.Facet)
+	aggRes, err := parseAgg(args.Agg)
+	if err != nil {
+		return nil, badRequestError{err}
+	}
+
+	facetSize := DefaultFacetSize
+	if n, err := strconv.Atoi(args.FacetSize); err == nil && n > 0 {
+		facetSize = n
+	}
+
+	var hl *highlight
+	if hlFields := parseFl(args.Hl); hlFields != nil {
+		hl = &highlight{
+			fields:   hlFields,
+			pre:      args.HlPre,
+			post:     args.HlPost,
+			fragSize: DefaultHlFragSize,
+			snippets: DefaultHlSnippets,
+		}
+		if hl.pre == "" && hl.post == "" {
+			hl.pre, hl.post = DefaultHlPre, DefaultHlPost
+		}
+		if n, err := strconv.Atoi(args.HlFragSize); err == nil && n >= 0 {
+			hl.fragSize = n
+		}
+		if n, err := strconv.Atoi(args.HlSnippets); err == nil && n > 0 {
+			hl.snippets = n
+		}
+	}
 
 	nRows := 20
-	if len(pagesize) > 0 {
-		nRows, _ = strconv.Atoi(pagesize)
+	if len(args.PageSize) > 0 {
+		nRows, _ = strconv.Atoi(args.PageSize)
 		if nRows <= 0 {
 			nRows = 20
 		} else if nRows > 100 {
@@ -35,58 +67,30 @@ func parseQuery(q, fq, s, f, page, pagesize, fl string) (*parsedQuery, error) {
 		}
 	}
 	nStart := 0
-	if len(page) > 0 {
-		if n, err := strconv.Atoi(page); err == nil && n > 0 {
+	if len(args.Page) > 0 && !args.HasAfter {
+		if n, err := strconv.Atoi(args.Page); err == nil && n > 0 {
 			nStart = (n - 1) * nRows
 		}
 	}
 
 	return &parsedQuery{
-		query:        qRes,
-		labels:       qLabels,
+		expr:         qExpr,
 		fquerys:      fqRes,
 		sortBys:      sRes,
 		filters:      fRes,
 		start:        nStart,
 		rows:         nRows,
 		outFieldList: flRes,
+		facetFields:  facetRes,
+		facetSize:    facetSize,
+		aggs:         aggRes,
+		hl:           hl,
+		explain:      args.Explain,
+		useCursor:    args.HasAfter,
+		after:        args.After,
 	}, nil
 }
 
-// q: +must should -notIn
-func parseQ(q string) (*query, error) {
-	fs := fieldsKeepQuote(q)
-	if len(fs) == 0 {
-		return nil, fmt.Errorf("q parameter expected")
-	}
-
-	res := &query{
-		should: []string{},
-		must:   []string{},
-		notIn:  []string{},
-	}
-	for _, f := range fs {
-		switch f[0] {
-		case '+':
-			if len(f) > 1 {
-				res.must = append(res.must, f[1:])
-			}
-		case '-':
-			if len(f) > 1 {
-				res.notIn = append(res.notIn, f[1:])
-			}
-		default:
-			res.should = append(res.should, f)
-		}
-	}
-
-	if len(res.should) == 0 && len(res.must) == 0 && len(res.notIn) == 0 {
-		return nil, fmt.Errorf("q parameter expected")
-	}
-
-	return res, nil
-}
-
 // fq: f1:q-in-field,f2:q-field,...
 func parseFq(fq string) ([]fquery, error) {
 	fs := fieldsKeepQuote(fq, ',', ';')
@@ -100,14 +104,15 @@ func parseFq(fq string) ([]fquery, error) {
 		if pos <= 0 {
 			continue
 		}
-		q, err := parseQ(f[pos+1:])
+		expr, err := parseQExpr(f[pos+1:])
 		if err != nil {
 			return nil, err
 		}
-		if q == nil {
+		if expr == nil {
 			continue
 		}
-		res = append(res, fquery{fieldName: f[:pos], query: q})
+		setDefaultField(expr, f[:pos])
+		res = append(res, fquery{fieldName: f[:pos], expr: expr})
 	}
 
 	if len(res) == 0 {
@@ -116,8 +121,8 @@ func parseFq(fq string) ([]fquery, error) {
 	return res, nil
 }
 
-// s: f1:desc,f2:asc
-func parseS(s string) []sorting {
+// s: f1:desc,f2:asc,f3:asc:pinyin
+func parseS(s string) ([]sorting, error) {
 	fs := strings.FieldsFunc(s, func(c rune) bool { return (c == ',' || c == ';') })
 
 	res := []sorting{}
@@ -131,19 +136,21 @@ func parseS(s string) []sorting {
 		case 1:
 			res = append(res, sorting{fieldName: ss[0]})
 		default:
-			switch ss[1] {
-			case "asc":
-				res = append(res, sorting{fieldName: ss[0], asc: true})
-			default:
-				res = append(res, sorting{fieldName: ss[0]})
+			sortBy := sorting{fieldName: ss[0], asc: ss[1] == "asc"}
+			if len(ss) > 2 {
+				if err := checkCollation(ss[2]); err != nil {
+					return nil, err
+				}
+				sortBy.collation = ss[2]
 			}
+			res = append(res, sortBy)
 		}
 	}
 
 	if len(res) == 0 {
-		return nil
+		return nil, nil
 	}
-	return res
+	return res, nil
 }
 
 // f: f1:filter1,filter2|f2:filter1,filter2|f3:r1~r2,r3~r4
@@ -190,6 +197,28 @@ func parseF(f string) ([]filter, error) {
 	return res, nil
 }
 
+// agg: f1:range:0~100,100~500|f2:histogram:100|f3:date_histogram:month|f4:stats
+func parseAgg(agg string) ([]aggSpec, error) {
+	as := fieldsKeepQuote(agg, '|')
+	if len(as) == 0 {
+		return nil, nil
+	}
+
+	res := []aggSpec{}
+	for _, a := range as {
+		ss := strings.SplitN(a, ":", 3)
+		if len(ss) < 2 || ss[0] == "" {
+			return nil, fmt.Errorf("bad agg %s", a)
+		}
+		spec := aggSpec{fieldName: ss[0], aggType: ss[1]}
+		if len(ss) == 3 {
+			spec.params = ss[2]
+		}
+		res = append(res, spec)
+	}
+	return res, nil
+}
+
 // fl: f1,f2,...
 func parseFl(fl string) []string {
 	l := strings.FieldsFunc(fl, func(c rune) bool { return (c == ',' || c == ' ') })
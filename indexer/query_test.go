@@ -0,0 +1,70 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 执行查询，返回结果中的doc
+func queryDocs(t *testing.T, index string, args *QueryArgs) []StoredDoc {
+	res, err := Query(index, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []StoredDoc
+	if res.Docs != nil {
+		for doc := range res.Docs {
+			docs = append(docs, doc.(StoredDoc))
+		}
+	}
+	return docs
+}
+
+// 结果doc的id排序后用','连接
+func docIds(docs []StoredDoc) string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = fmt.Sprintf("%v", doc["id"])
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, ",")
+}
+
+func Test_Query_analyzedFields(t *testing.T) {
+	schemaJSON := `{"fields": [
+		{"name": "id", "type": "u32", "pk": true},
+		{"name": "name"},
+		{"name": "title", "analyzer": "english"}
+	]}`
+	index, teardown := setupTestIndex(t, schemaJSON, jsonDocs(`[
+		{"id": 1, "name": "red shoes", "title": "Running Shoes of the Year"},
+		{"id": 2, "name": "running hat", "title": "A Hat"},
+		{"id": 3, "name": "blue shirt", "title": "Runs Daily"}
+	]`))
+	defer teardown()
+
+	cases := []struct {
+		args     QueryArgs
+		expected string
+	}{
+		// 不指定字段的查询词按每个字段的分析器分词
+		{QueryArgs{Q: "running"}, "1,2,3"},
+		{QueryArgs{Q: "+running +shoes"}, "1"},
+		{QueryArgs{Q: "shoe"}, "1"},
+		{QueryArgs{Q: "runs -hat"}, "1,3"},
+		{QueryArgs{Q: "title:running"}, "1,3"},
+		{QueryArgs{Q: "name:running"}, "2"},
+		{QueryArgs{Q: `"running shoes"`}, "1"},
+		// 过滤条件等于字段分析后的某个token
+		{QueryArgs{F: "title:running"}, "1,3"},
+		{QueryArgs{F: "title:THE"}, ""},
+		{QueryArgs{F: "title:running shoes"}, ""},
+	}
+	for i, c := range cases {
+		if ids := docIds(queryDocs(t, index, &c.args)); ids != c.expected {
+			t.Errorf("case #%d %+v: expected %q, got %q", i, c.args, c.expected, ids)
+		}
+	}
+}
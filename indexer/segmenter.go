@@ -0,0 +1,100 @@
+package indexer
+
+import (
+	"bufio"
+	"go-search/conf"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/go-ego/gse"
+)
+
+var (
+	segmenter *gse.Segmenter  // 中文分词器，没有配置词典时为nil，按单个汉字分词
+	stopWords map[string]bool // 中文分词后去掉的停用词
+)
+
+// 按配置的seg-dict加载中文分词词典和停用词，所有索引库共用
+func InitSegmenter() error {
+	segDict := &conf.ServiceConf.SegDict
+	if segDict.DictFile == "" {
+		log.Printf("[warning] seg-dict/dict-file not set, zh fields will be tokenized by single hanzi\n")
+		return nil
+	}
+
+	seg := &gse.Segmenter{}
+	if err := seg.LoadDict(segDict.DictFile); err != nil {
+		return err
+	}
+	if segDict.StopFile != "" {
+		words, err := loadStopWords(segDict.StopFile)
+		if err != nil {
+			return err
+		}
+		stopWords = words
+	}
+	segmenter = seg
+	log.Printf("segmenter initialized with %d words, %d stop words\n", seg.Dictionary().NumTokens(), len(stopWords))
+	return nil
+}
+
+// 停用词文件每行一个词
+func loadStopWords(stopFile string) (map[string]bool, error) {
+	fp, err := os.Open(stopFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	words := map[string]bool{}
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			words[w] = true
+		}
+	}
+	return words, scanner.Err()
+}
+
+// zh分词器，建索引和查询都使用。先按空白、标点切分，含有汉字的部分再用词典分词
+func zhTokenize(s string, keepIt ...rune) []string {
+	if segmenter == nil {
+		return hanziTokenize(s, keepIt...)
+	}
+
+	tokens := []string{}
+	for _, t := range tokenizeI(s, false, keepIt...) {
+		if !hasHanzi(t) {
+			tokens = append(tokens, t)
+			continue
+		}
+		for _, w := range segmenter.Cut(t, true) {
+			if isSeparator(w) || stopWords[w] {
+				continue
+			}
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
+
+func hasHanzi(s string) bool {
+	for _, ch := range s {
+		if unicode.In(ch, unicode.Han) {
+			return true
+		}
+	}
+	return false
+}
+
+// 分词结果中只有空白、标点的词
+func isSeparator(w string) bool {
+	for _, ch := range w {
+		if !unicode.IsSpace(ch) && !unicode.IsPunct(ch) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package indexer
+
+import (
+	"go-search/conf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_zhTokenize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "seg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dictFile, stopFile := filepath.Join(dir, "dict.txt"), filepath.Join(dir, "stop.txt")
+	_ = ioutil.WriteFile(dictFile, []byte("红色 100 n\n鞋子 100 n\n的 100 u\n"), 0644)
+	_ = ioutil.WriteFile(stopFile, []byte("的\n"), 0644)
+
+	conf.ServiceConf.SegDict.DictFile, conf.ServiceConf.SegDict.StopFile = dictFile, stopFile
+	defer func() {
+		conf.ServiceConf.SegDict.DictFile, conf.ServiceConf.SegDict.StopFile = "", ""
+		segmenter, stopWords = nil, nil
+	}()
+	if err = InitSegmenter(); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := zhTokenize("红色的鞋子, size:42")
+	if strings.Join(tokens, "|") != "红色|鞋子|size:42" {
+		t.Errorf("unexpected tokens %q", tokens)
+	}
+}
@@ -0,0 +1,249 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-ego/gpy"
+	"github.com/go-ego/riot/types"
+)
+
+// 字符串排序规则
+const (
+	CollationBytes  = "bytes"  // 按字节序，缺省
+	CollationPinyin = "pinyin" // 汉字按拼音、声调排序，其它字符不区分大小写
+)
+
+// 每个排序字段在打分中占用的float32个数: 是否有值，以及保序转换为uint64后分成的3段
+// 每段不超过22位，可以用float32精确表示，搜索引擎按打分逐个比较时就是按原值比较
+const sortKeyWidth = 4
+
+var pinyinArgs = gpy.Args{Style: gpy.Tone3, Fallback: func(r rune, a gpy.Args) []string { return nil }}
+
+func checkCollation(collation string) error {
+	switch collation {
+	case "", CollationBytes, CollationPinyin:
+		return nil
+	default:
+		return fmt.Errorf("unknown collation %s", collation)
+	}
+}
+
+// 生成字符串的排序键，按字节序比较排序键就是按排序规则比较
+func collationKey(s, collation string) string {
+	if collation != CollationPinyin {
+		return s
+	}
+
+	sb := &strings.Builder{}
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			if pys := gpy.SinglePinyin(r, pinyinArgs); len(pys) > 0 {
+				sb.WriteString(pys[0])
+				sb.WriteByte(1) // 音节分隔，比所有可见字符小
+				continue
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+// 生成排序打分，降序时直接用各段的值，升序时用各段的相反数，没有值的doc排在最后
+func (d StoredDoc) score(sortBys []sorting) []float32 {
+	output := make([]float32, len(sortBys)*sortKeyWidth)
+	for i, sortBy := range sortBys {
+		u, ok := sortKeyBits(d[sortBy.fieldName])
+		if !ok {
+			continue
+		}
+		key := output[i*sortKeyWidth : (i+1)*sortKeyWidth]
+		key[0] = 1
+		key[1], key[2], key[3] = float32(u>>42), float32((u>>21)&0x1fffff), float32(u&0x1fffff)
+		if sortBy.asc {
+			key[1], key[2], key[3] = 0-key[1], 0-key[2], 0-key[3] // 0-0是+0，避免输出-0
+		}
+	}
+	return output
+}
+
+// 排序字段都相同时按docID的hash升序排列的打分，搜索引擎不保证打分相同的doc的顺序，游标分页时需要确定的顺序
+func docIdScore(docId string) []float32 {
+	u := docIdKey(docId)
+	return []float32{0 - float32(u>>42), 0 - float32((u>>21)&0x1fffff), 0 - float32(u&0x1fffff)}
+}
+
+func docIdKey(docId string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(docId))
+	return h.Sum64()
+}
+
+// 把数值、时间、bool转换为保序的uint64，字符串等不能转换的返回false
+func sortKeyBits(v interface{}) (uint64, bool) {
+	if v == nil {
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch i := v.(type) {
+	case int8, int16, int32, int64, int:
+		return uint64(rv.Int()) ^ (1 << 63), true
+	case uint8, uint16, uint32, uint64, uint:
+		return rv.Uint(), true
+	case float32, float64:
+		bits := math.Float64bits(rv.Float())
+		if bits&(1<<63) != 0 {
+			return ^bits, true
+		}
+		return bits | (1 << 63), true
+	case bool:
+		if i {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// 是否有字符串排序字段，字符串不能转换为打分，需要取出所有结果后排序
+func hasStringSorting(sortBys []sorting, schema *indexSchema) bool {
+	for _, sortBy := range sortBys {
+		if isStringField(schema, sortBy.fIdx) {
+			return true
+		}
+	}
+	return false
+}
+
+func isStringField(schema *indexSchema, fIdx int) bool {
+	switch schema.Fields[fIdx].Type {
+	case conf.StringType, conf.StringStrType:
+		return true
+	default:
+		return false
+	}
+}
+
+// 一个排序字段的值，字符串使用排序键，其它使用保序转换后的uint64
+type sortValue struct {
+	ok bool // 是否有值
+	s  string
+	u  uint64
+}
+
+// 生成doc所有排序字段的值
+func docSortValues(doc StoredDoc, sortBys []sorting, schema *indexSchema) []sortValue {
+	values := make([]sortValue, len(sortBys))
+	for i, sortBy := range sortBys {
+		v := &values[i]
+		if isStringField(schema, sortBy.fIdx) {
+			var s string
+			if s, v.ok = doc[sortBy.fieldName].(string); v.ok {
+				v.s = collationKey(s, sortBy.collation)
+			}
+		} else {
+			v.u, v.ok = sortKeyBits(doc[sortBy.fieldName])
+		}
+	}
+	return values
+}
+
+// 按排序条件比较，a在前返回负数，没有值的排在后面，所有排序字段都相同时按docID的hash、docID升序
+func compareSortValues(a, b []sortValue, idA, idB string, sortBys []sorting) int {
+	for i, sortBy := range sortBys {
+		va, vb := &a[i], &b[i]
+		switch {
+		case !va.ok && !vb.ok:
+			continue
+		case !va.ok:
+			return 1
+		case !vb.ok:
+			return -1
+		}
+		c := strings.Compare(va.s, vb.s)
+		if c == 0 {
+			switch {
+			case va.u < vb.u:
+				c = -1
+			case va.u > vb.u:
+				c = 1
+			}
+		}
+		if c != 0 {
+			if !sortBy.asc {
+				c = -c
+			}
+			return c
+		}
+	}
+	if ka, kb := docIdKey(idA), docIdKey(idB); ka != kb {
+		if ka < kb {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(idA, idB)
+}
+
+// 对所有结果排序，然后取出当前页
+func sortResult(searchResp *types.SearchResp, pq *parsedQuery, schema *indexSchema) {
+	docs, ok := searchResp.Docs.(types.ScoredDocs)
+	if !ok || len(docs) == 0 {
+		return
+	}
+
+	values := make([][]sortValue, len(docs))
+	for j := range docs {
+		storedDoc, _ := docs[j].Fields.(StoredDoc)
+		values[j] = docSortValues(storedDoc, pq.sortBys, schema)
+	}
+
+	idx := make([]int, len(docs))
+	for j := range idx {
+		idx[j] = j
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		da, db := idx[a], idx[b]
+		return compareSortValues(values[da], values[db], docs[da].DocId, docs[db].DocId, pq.sortBys) < 0
+	})
+
+	start, end := pq.start, pq.start+pq.rows
+	if start > len(idx) {
+		start = len(idx)
+	}
+	if end > len(idx) {
+		end = len(idx)
+	}
+	page := make(types.ScoredDocs, end-start)
+	for j := start; j < end; j++ {
+		page[j-start] = docs[idx[j]]
+	}
+	searchResp.Docs = page
+
+	// 还有结果时生成下一页的游标
+	if pq.cursor != nil && end < len(idx) && end > start {
+		last := idx[end-1]
+		pq.nextCursor = encodeCursor(values[last], docs[last].DocId, pq.sortBys, schema)
+	}
+}
+
+// 游标分页时搜索引擎多返回一个doc，有这个doc时去掉它并生成下一页的游标
+func cutCursorPage(searchResp *types.SearchResp, pq *parsedQuery, schema *indexSchema) {
+	docs, ok := searchResp.Docs.(types.ScoredDocs)
+	if !ok || len(docs) <= pq.rows {
+		return
+	}
+	docs = docs[:pq.rows]
+	searchResp.Docs = docs
+
+	last := &docs[len(docs)-1]
+	storedDoc, _ := last.Fields.(StoredDoc)
+	pq.nextCursor = encodeCursor(docSortValues(storedDoc, pq.sortBys, schema), last.DocId, pq.sortBys, schema)
+}
@@ -0,0 +1,52 @@
+package indexer
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_collationKey(t *testing.T) {
+	words := []string{"绿帽子", "Apple", "蓝色衬衫", "红色", "banana", "蓝色的鞋"}
+	sort.Slice(words, func(i, j int) bool {
+		return collationKey(words[i], CollationPinyin) < collationKey(words[j], CollationPinyin)
+	})
+	if s := strings.Join(words, ","); s != "Apple,banana,红色,蓝色衬衫,蓝色的鞋,绿帽子" {
+		t.Errorf("unexpected pinyin order: %s", s)
+	}
+}
+
+func Test_sortingScore(t *testing.T) {
+	// 按打分逐个比较的结果必须和按原值比较的结果一致
+	less := func(a, b []float32) bool {
+		for i := range a {
+			if a[i] != b[i] {
+				return a[i] > b[i]
+			}
+		}
+		return false
+	}
+	cases := [][]interface{}{
+		{int64(math.MinInt64), int64(-1), int64(0), int64(1 << 40), int64(1<<40 + 1), int64(math.MaxInt64)},
+		{uint64(0), uint64(1<<53 + 1), uint64(1<<53 + 2), uint64(math.MaxUint64)},
+		{math.Inf(-1), -2.5, float64(0), 1e-10, 3.14, float32(3.15)},
+		{int64(1569895200000000001), int64(1569895200000000002)},
+		{false, true},
+	}
+	for _, values := range cases {
+		for _, asc := range []bool{true, false} {
+			sortBys := []sorting{{fieldName: "v", asc: asc}}
+			for i := 1; i < len(values); i++ {
+				prev := StoredDoc{"v": values[i-1]}.score(sortBys)
+				curr := StoredDoc{"v": values[i]}.score(sortBys)
+				if less(prev, curr) != asc || less(curr, prev) == asc {
+					t.Errorf("asc=%v: %v should be before %v", asc, values[i-1], values[i])
+				}
+			}
+			if !less(StoredDoc{"v": values[0]}.score(sortBys), StoredDoc{}.score(sortBys)) {
+				t.Errorf("asc=%v: doc without value should be the last", asc)
+			}
+		}
+	}
+}
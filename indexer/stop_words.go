@@ -0,0 +1,108 @@
+package indexer
+
+// 各语言的停用词，来自Snowball项目的停用词表
+var stopWordLists = map[string][]string{
+	"english": {
+		"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "as",
+		"at", "be", "because", "been", "before", "being", "below", "between", "both", "but", "by", "can",
+		"did", "do", "does", "doing", "don", "down", "during", "each", "few", "for", "from", "further",
+		"had", "has", "have", "having", "he", "her", "here", "hers", "herself", "him", "himself", "his",
+		"how", "i", "if", "in", "into", "is", "it", "its", "itself", "just", "me", "more", "most", "my",
+		"myself", "no", "nor", "not", "now", "of", "off", "on", "once", "only", "or", "other", "our",
+		"ours", "ourselves", "out", "over", "own", "s", "same", "she", "should", "so", "some", "such",
+		"t", "than", "that", "the", "their", "theirs", "them", "themselves", "then", "there", "these",
+		"they", "this", "those", "through", "to", "too", "under", "until", "up", "very", "was", "we",
+		"were", "what", "when", "where", "which", "while", "who", "whom", "why", "will", "with", "you",
+		"your", "yours", "yourself", "yourselves",
+	},
+	"french": {
+		"au", "aux", "avec", "ce", "ces", "dans", "de", "des", "du", "elle", "en", "et", "eux", "il",
+		"je", "la", "le", "leur", "lui", "ma", "mais", "me", "même", "mes", "moi", "mon", "ne", "nos",
+		"notre", "nous", "on", "ou", "par", "pas", "pour", "qu", "que", "qui", "sa", "se", "ses", "son",
+		"sur", "ta", "te", "tes", "toi", "ton", "tu", "un", "une", "vos", "votre", "vous", "c", "d", "j",
+		"l", "à", "m", "n", "s", "t", "y", "été", "étée", "étées", "étés", "étant", "étante", "étants",
+		"étantes", "suis", "es", "est", "sommes", "êtes", "sont", "serai", "seras", "sera", "serons",
+		"serez", "seront", "serais", "serait", "serions", "seriez", "seraient", "étais", "était",
+		"étions", "étiez", "étaient", "fus", "fut", "fûmes", "fûtes", "furent", "sois", "soit", "soyons",
+		"soyez", "soient", "fusse", "fusses", "fût", "fussions", "fussiez", "fussent", "ayant", "ayante",
+		"ayantes", "ayants", "eu", "eue", "eues", "eus", "ai", "as", "avons", "avez", "ont", "aurai",
+		"auras", "aura", "aurons", "aurez", "auront", "aurais", "aurait", "aurions", "auriez",
+		"auraient", "avais", "avait", "avions", "aviez", "avaient", "eut", "eûmes", "eûtes", "eurent",
+		"aie", "aies", "ait", "ayons", "ayez", "aient", "eusse", "eusses", "eût", "eussions", "eussiez",
+		"eussent",
+	},
+	"spanish": {
+		"de", "la", "que", "el", "en", "y", "a", "los", "del", "se", "las", "por", "un", "para", "con",
+		"no", "una", "su", "al", "lo", "como", "más", "pero", "sus", "le", "ya", "o", "este", "sí",
+		"porque", "esta", "entre", "cuando", "muy", "sin", "sobre", "también", "me", "hasta", "hay",
+		"donde", "quien", "desde", "todo", "nos", "durante", "todos", "uno", "les", "ni", "contra",
+		"otros", "ese", "eso", "ante", "ellos", "e", "esto", "mí", "antes", "algunos", "qué", "unos",
+		"yo", "otro", "otras", "otra", "él", "tanto", "esa", "estos", "mucho", "quienes", "nada",
+		"muchos", "cual", "poco", "ella", "estar", "estas", "algunas", "algo", "nosotros", "mi", "mis",
+		"tú", "te", "ti", "tu", "tus", "ellas", "nosotras", "vosostros", "vosostras", "os", "mío", "mía",
+		"míos", "mías", "tuyo", "tuya", "tuyos", "tuyas", "suyo", "suya", "suyos", "suyas", "nuestro",
+		"nuestra", "nuestros", "nuestras", "vuestro", "vuestra", "vuestros", "vuestras", "esos", "esas",
+		"estoy", "estás", "está", "estamos", "estáis", "están", "esté", "estés", "estemos", "estéis",
+		"estén", "estaré", "estarás", "estará", "estaremos", "estaréis", "estarán", "estaría",
+		"estarías", "estaríamos", "estaríais", "estarían", "estaba", "estabas", "estábamos", "estabais",
+		"estaban", "estuve", "estuviste", "estuvo", "estuvimos", "estuvisteis", "estuvieron",
+		"estuviera", "estuvieras", "estuviéramos", "estuvierais", "estuvieran", "estuviese",
+		"estuvieses", "estuviésemos", "estuvieseis", "estuviesen", "estando", "estado", "estada",
+		"estados", "estadas", "estad", "he", "has", "ha", "hemos", "habéis", "han", "haya", "hayas",
+		"hayamos", "hayáis", "hayan", "habré", "habrás", "habrá", "habremos", "habréis", "habrán",
+		"habría", "habrías", "habríamos", "habríais", "habrían", "había", "habías", "habíamos",
+		"habíais", "habían", "hube", "hubiste", "hubo", "hubimos", "hubisteis", "hubieron", "hubiera",
+		"hubieras", "hubiéramos", "hubierais", "hubieran", "hubiese", "hubieses", "hubiésemos",
+		"hubieseis", "hubiesen", "habiendo", "habido", "habida", "habidos", "habidas", "soy", "eres",
+		"es", "somos", "sois", "son", "sea", "seas", "seamos", "seáis", "sean", "seré", "serás", "será",
+		"seremos", "seréis", "serán", "sería", "serías", "seríamos", "seríais", "serían", "era", "eras",
+		"éramos", "erais", "eran", "fui", "fuiste", "fue", "fuimos", "fuisteis", "fueron", "fuera",
+		"fueras", "fuéramos", "fuerais", "fueran", "fuese", "fueses", "fuésemos", "fueseis", "fuesen",
+		"sintiendo", "sentido", "sentida", "sentidos", "sentidas", "siente", "sentid", "tengo", "tienes",
+		"tiene", "tenemos", "tenéis", "tienen", "tenga", "tengas", "tengamos", "tengáis", "tengan",
+		"tendré", "tendrás", "tendrá", "tendremos", "tendréis", "tendrán", "tendría", "tendrías",
+		"tendríamos", "tendríais", "tendrían", "tenía", "tenías", "teníamos", "teníais", "tenían",
+		"tuve", "tuviste", "tuvo", "tuvimos", "tuvisteis", "tuvieron", "tuviera", "tuvieras",
+		"tuviéramos", "tuvierais", "tuvieran", "tuviese", "tuvieses", "tuviésemos", "tuvieseis",
+		"tuviesen", "teniendo", "tenido", "tenida", "tenidos", "tenidas", "tened",
+	},
+	"russian": {
+		"и", "в", "во", "не", "что", "он", "на", "я", "с", "со", "как", "а", "то", "все", "она", "так",
+		"его", "но", "да", "ты", "к", "у", "же", "вы", "за", "бы", "по", "только", "ее", "мне", "было",
+		"вот", "от", "меня", "еще", "нет", "о", "из", "ему", "теперь", "когда", "даже", "ну", "вдруг",
+		"ли", "если", "уже", "или", "ни", "быть", "был", "него", "до", "вас", "нибудь", "опять", "уж",
+		"вам", "ведь", "там", "потом", "себя", "ничего", "ей", "может", "они", "тут", "где", "есть",
+		"надо", "ней", "для", "мы", "тебя", "их", "чем", "была", "сам", "чтоб", "без", "будто", "чего",
+		"раз", "тоже", "себе", "под", "будет", "ж", "тогда", "кто", "этот", "того", "потому", "этого",
+		"какой", "совсем", "ним", "здесь", "этом", "один", "почти", "мой", "тем", "чтобы", "нее",
+		"сейчас", "были", "куда", "зачем", "всех", "никогда", "можно", "при", "наконец", "два", "об",
+		"другой", "хоть", "после", "над", "больше", "тот", "через", "эти", "нас", "про", "всего", "них",
+		"какая", "много", "разве", "три", "эту", "моя", "впрочем", "хорошо", "свою", "этой", "перед",
+		"иногда", "лучше", "чуть", "том", "нельзя", "такой", "им", "более", "всегда", "конечно", "всю",
+		"между",
+	},
+	"swedish": {
+		"och", "det", "att", "i", "en", "jag", "hon", "som", "han", "på", "den", "med", "var", "sig",
+		"för", "så", "till", "är", "men", "ett", "om", "hade", "de", "av", "icke", "mig", "du", "henne",
+		"då", "sin", "nu", "har", "inte", "hans", "honom", "skulle", "hennes", "där", "min", "man", "ej",
+		"vid", "kunde", "något", "från", "ut", "när", "efter", "upp", "vi", "dem", "vara", "vad", "över",
+		"än", "dig", "kan", "sina", "här", "ha", "mot", "alla", "under", "någon", "eller", "allt",
+		"mycket", "sedan", "ju", "denna", "själv", "detta", "åt", "utan", "varit", "hur", "ingen",
+		"mitt", "ni", "bli", "blev", "oss", "din", "dessa", "några", "deras", "blir", "mina", "samma",
+		"vilken", "er", "sådan", "vår", "blivit", "dess", "inom", "mellan", "sådant", "varför", "varje",
+		"vilka", "ditt", "vem", "vilket", "sitta", "sådana", "vart", "dina", "vars", "vårt", "våra",
+		"ert", "era", "vilkas",
+	},
+	"norwegian": {
+		"ut", "få", "hadde", "hva", "tilbake", "vil", "han", "meget", "men", "vi", "en", "før", "samme",
+		"stille", "inn", "er", "kan", "makt", "ved", "forsøke", "hvis", "part", "rett", "måte", "denne",
+		"mer", "i", "lang", "ny", "hans", "hvilken", "tid", "vite", "her", "opp", "var", "navn", "mye",
+		"om", "sant", "tilstand", "der", "ikke", "mest", "punkt", "hvem", "skulle", "mange", "over",
+		"vårt", "alle", "arbeid", "lik", "like", "gå", "når", "siden", "å", "begge", "bruke", "eller",
+		"og", "til", "da", "et", "hvorfor", "nå", "sist", "slutt", "deres", "det", "hennes", "så",
+		"mens", "bra", "din", "fordi", "gjøre", "god", "ha", "start", "andre", "må", "med", "under",
+		"meg", "oss", "innen", "på", "verdi", "ville", "kunne", "uten", "vår", "slik", "ene", "folk",
+		"min", "riktig", "enhver", "bort", "enn", "nei", "som", "våre", "disse", "gjorde", "lage", "si",
+		"du", "fra", "også", "hvordan", "av", "eneste", "for", "hvor", "først", "hver",
+	},
+}
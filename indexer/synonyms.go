@@ -0,0 +1,158 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"log"
+	"strings"
+)
+
+// 索引库的同义词: 查询词 -> 替换后的查询词，查询时把查询词扩展为替换后的查询词中的任意一个
+type synonymSet struct {
+	words map[string][]string // key是规范化后的查询词
+}
+
+// 解析同义词规则，规则的格式见conf/synonyms.go
+//   - "a, b, c": a、b、c相互替换
+//   - "a, b => c, d": a、b替换为c或d，c、d不会替换为a、b
+//
+// 一个词出现在多条规则中时，替换结果合并
+func parseSynonyms(schema *indexSchema, rules []string) (*synonymSet, error) {
+	set := &synonymSet{words: map[string][]string{}}
+	for i, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" || rule[0] == '#' {
+			continue
+		}
+
+		parts := strings.Split(rule, "=>")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("rule #%d %q: more than one \"=>\"", i+1, rule)
+		}
+		from, err := splitSynonyms(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d %q: %v", i+1, rule, err)
+		}
+		to := from
+		if len(parts) == 2 {
+			if to, err = splitSynonyms(parts[1]); err != nil {
+				return nil, fmt.Errorf("rule #%d %q: %v", i+1, rule, err)
+			}
+		} else if len(from) < 2 {
+			return nil, fmt.Errorf("rule #%d %q: at least 2 words needed", i+1, rule)
+		}
+
+		for _, w := range from {
+			key := synonymKey(schema, w)
+			set.words[key] = appendSynonyms(set.words[key], to)
+		}
+	}
+	return set, nil
+}
+
+func splitSynonyms(s string) ([]string, error) {
+	words := strings.Split(s, ",")
+	for i, w := range words {
+		if w = strings.Join(strings.Fields(w), " "); w == "" {
+			return nil, fmt.Errorf("empty word")
+		}
+		words[i] = w
+	}
+	return words, nil
+}
+
+func appendSynonyms(words []string, more []string) []string {
+	for _, m := range more {
+		found := false
+		for _, w := range words {
+			if w == m {
+				found = true
+				break
+			}
+		}
+		if !found {
+			words = append(words, m)
+		}
+	}
+	return words
+}
+
+// 查询词规范化后再查找同义词，不区分大小写，多个空白等同于一个空格
+func synonymKey(schema *indexSchema, s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(normalizeText(schema, s)), " "))
+}
+
+// 查询词的同义词，没有同义词时返回nil
+func (idx *indexer) synonymsOf(s string) []string {
+	idx.synonymLock.RLock()
+	set := idx.synonyms
+	idx.synonymLock.RUnlock()
+
+	if set == nil {
+		return nil
+	}
+	return set.words[synonymKey(idx.schema, s)]
+}
+
+// 替换索引库的同义词，set为nil时不使用同义词
+func (idx *indexer) setSynonyms(set *synonymSet) {
+	idx.synonymLock.Lock()
+	idx.synonyms = set
+	idx.synonymLock.Unlock()
+}
+
+// 加载索引库时读取保存的同义词，出错时不使用同义词
+func (idx *indexer) loadSynonyms() {
+	rules, err := conf.LoadSynonyms(idx.schema.Name)
+	if err != nil {
+		log.Printf("[synonyms] failed to load synonyms of %s: %v\n", idx.schema.Name, err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+	set, err := parseSynonyms(idx.schema, rules)
+	if err != nil {
+		log.Printf("[synonyms] failed to parse synonyms of %s: %v\n", idx.schema.Name, err)
+		return
+	}
+	idx.setSynonyms(set)
+}
+
+// 保存索引库的同义词规则，已经加载的索引库立即使用新的同义词，不需要重建索引
+func SetSynonyms(index string, rules []string) error {
+	schemaConf, err := conf.LoadSchema(index)
+	if err != nil {
+		return fmt.Errorf("schema of %s not found", index)
+	}
+	schema, err := newIndexSchema(schemaConf)
+	if err != nil {
+		return err
+	}
+	set, err := parseSynonyms(schema, rules)
+	if err != nil {
+		return err
+	}
+	if err = conf.SaveSynonyms(index, rules); err != nil {
+		return err
+	}
+
+	indexerLock.RLock()
+	idx, ok := indexers[index]
+	indexerLock.RUnlock()
+	if ok {
+		if len(set.words) == 0 {
+			set = nil
+		}
+		idx.setSynonyms(set)
+	}
+	return nil
+}
+
+// 获取索引库的同义词规则
+func GetSynonyms(index string) ([]string, error) {
+	if _, err := conf.LoadSchema(index); err != nil {
+		return nil, fmt.Errorf("schema of %s not found", index)
+	}
+	return conf.LoadSynonyms(index)
+}
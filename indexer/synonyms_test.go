@@ -0,0 +1,32 @@
+package indexer
+
+import (
+	"go-search/conf"
+	"strings"
+	"testing"
+)
+
+func Test_parseSynonyms(t *testing.T) {
+	schema := &indexSchema{Schema: &conf.Schema{SchemaConf: &conf.SchemaConf{}}}
+	set, err := parseSynonyms(schema, []string{"tv, television", "# comment", "TV => telly", "NYC => new  york"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"tv":         "tv|television|telly",
+		"television": "tv|television",
+		"nyc":        "new york",
+		"telly":      "",
+	}
+	for word, expected := range cases {
+		if synonyms := strings.Join(set.words[synonymKey(schema, word)], "|"); synonyms != expected {
+			t.Errorf("%s: expected %s, got %s", word, expected, synonyms)
+		}
+	}
+
+	for _, rule := range []string{"tv", "a => ", "a, , b", "a => b => c"} {
+		if _, err = parseSynonyms(schema, []string{rule}); err == nil {
+			t.Errorf("rule %q should be rejected", rule)
+		}
+	}
+}
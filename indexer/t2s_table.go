@@ -0,0 +1,99 @@
+package indexer
+
+// 常用繁体字 -> 简体字，按繁体字的Unicode码排序，t2sFrom和t2sTo中相同位置的字对应
+var (
+	t2sFrom = "" +
+		"並亂亞佇佔來侖侶俁係俠倀倆倉個們倫偉側偵偽傑傖傘備傢傭傳傴債傷傾僂僅僉僑僕僞僥僨價儀儂億儈儉儐儔儕儘" +
+		"償優儲儷儺儻儼兌兒兗內兩冊冪凍凜凱別刪剄則剗剛剝剮剴創劃劇劉劊劌劍劑勁動務勝勞勢勩勱勳勵勸勻匭匯匱區" +
+		"協卻卽厙厭厲厴參叢吳呂咼員唄唸問啓啞啟喚喪喫喬單喲嗆嗇嗎嗚嗩嗶嘆嘍嘔嘖嘗嘜嘩嘮嘯嘰嘵嘸噁噓噝噠噥噦噯" +
+		"噲噴噸嚀嚇嚌嚐嚕嚦嚨嚮嚳嚴嚶囀囁囅囈囉囑囪圇國圍園圓圖團埡執堅堊堝堯報場塊塋塏塒塚塢塤塵塹墊墜墮墳墾" +
+		"壇壓壘壙壚壞壟壠壢壩壪壯壺壽夠夢夥夾奐奧奩奪奮妝妳姍娛婁婦婭媧媯媼媽嫋嫗嫵嫻嫿嬈嬋嬌嬙嬡嬤嬪嬰嬸孌孫" +
+		"學孿宮寢實寧審寫寬寵寶將專尋對導尷屆屍屜屢層屨屬岡峯峴島峽崍崗崢崬嵐嶄嶇嶔嶗嶠嶢嶧嶮嶸嶺嶼嶽巋巒巔巰" +
+		"巹帥師帳帶幀幃幗幘幟幣幫幬幹幾廁廂廄廈廎廚廝廠廢廣廩廬廳張強彈彌彎彙彥彫後徑從徠徵徹恆恥悅悵悶惡惱惲" +
+		"惻愛愜愴愷愾態慍慘慚慟慣慪慫慮慳慶憂憊憐憑憒憚憤憫憮憲憶懇應懌懟懣懨懲懶懷懸懺懼懾戀戇戔戧戩戰戲戶拋" +
+		"挾捨捫掃掄掙掛揀揚換揮損搖搗搶搾摑摜摟摯摳摶摻撈撏撐撓撟撣撥撫撲撳撻撾撿擁擄擇擊擋擔據擠擣擬擯擰擱擲" +
+		"擴擷擺擻擼擾攄攆攏攔攖攙攛攜攝攢攣攤攪攬敎敗敘敵數斂斃斕斬斷於旣時晉晝暈暉暘暢暫曄曆曇曉曖曠曬書會朧" +
+		"朮東柵梔梘條梟棄棖棗棟棧棲椏楊楓楨業極榦榪榮榿構槍槧槨槪槳槼樁樂樅樓標樞樣樸樹樺橈橋機橢橫檁檉檔檜檟" +
+		"檢檣檯檳檸檻櫃櫓櫚櫛櫝櫞櫟櫥櫧櫨櫪櫫櫬櫳櫸櫻欄權欏欒欖欞欽歐歟歡歲歷歸歿殘殞殤殫殮殯殲殺殼毀毆毿氈氣" +
+		"氫氬氳決沒況洶浹涇涼淒淚淨淪淵淶淺渙減渦測渾湊湞湯溈準溝溫溼滄滅滌滎滬滯滲滸滾滿漁漚漢漣漬漲漵漸漿潁" +
+		"潑潔潛潤潯潰潿澀澆澇澗澠澤澦澩澮濁濃濕濘濟濤濫濰濱濺濼濾瀆瀉瀋瀏瀕瀘瀝瀟瀠瀧瀨瀲瀾灃灑灘灝灣灤災為烏" +
+		"烴無煉煒煙煢煥煩煬熒熗熱熾燁燈燉燒燙燜營燦燬燭燴燼燾爍爐爛爭爲爺爾牆牘牠牽犖犛犢犧狀狹狽猙猶猻獁獃獄" +
+		"獅獎獨獪獫獮獰獲獵獷獸獺獻獼玀現琺琿瑋瑣瑤瑩瑪瑯璉璣璫環璽瓊瓏瓔瓚甌產畝畢畫異當疇疊痙痠痾瘂瘋瘍瘓瘞" +
+		"瘡瘧瘮瘻療癆癇癉癘癟癡癢癤癩癬癭癮癰癱癲發皚皰皸皺盃盜盞盡監盤盧眞眥眾睏瞘瞞瞼矚矯砲硃硜硤硨硯碩碭碸" +
+		"確碼磑磚磣磧磯磽礎礙礦礪礫礬礱祕祿禍禎禕禪禮禰禿秈稅稈種稱穀積穎穠穢穩穫窩窪窮窯窺竄竅竇竊競筆筍筧箋" +
+		"箏節範築篤篩篳簀簍簞簡簣簫簽簾籃籌籙籜籠籤籩籬籮籲粵糝糞糧糲糴糶糾紀紂約紅紆紇紈紉紋納紐紓純紕紖紗紙" +
+		"級紛紜紡紮細紱紲紳紹紺紼紿絀終組絆絎結絕絛絞絡絢給絨絰統絲絳絶絹綁綃綆綈綌綏綑經綜綞綠綢綣綫綬維綰綱" +
+		"網綴綸綹綺綻綽綾綿緄緇緊緋緒緗緘緙線緝緞締緡緣緦編緩緬緯緱緲練緶緹緻縈縉縊縋縐縑縛縝縞縟縣縫縭縮縱縲" +
+		"縴縵縶縷縹總績繃繅繆織繕繚繞繡繩繪繫繭繯繳繹繼繽纈纊續纍纏纓纔纖纘纜缽罈罌罰罵罷羅羆羈羋羥義習翹耬聖" +
+		"聞聯聰聲聳聵聶職聹聽聾肅脅脈脛脫脹腎腡腦腫腳腸膃膕膚膠膩膽膾膿臉臍臏臘臚臟臠臥臨臺與興舉舊舖艙艦艫艱" +
+		"芻苧茲莊莖莢莧華萇萊萬萵葉葒葤葦葷蒐蒔蒞蒼蓀蓋蓧蓮蓯蓴蓽蔔蔞蔣蔥蔦蔭蕁蕆蕎蕒蕓蕕蕘蕢蕩蕪蕭蕷薈薊薌薑" +
+		"薔薘薟薦薩薰薺藍藎藝藥藪藶藹藺蘀蘄蘆蘇蘊蘋蘚蘞蘢蘭蘺蘿處虛虜號虧虯蛺蛻蜆蝕蝟蝦蝸螄螞螢螻螿蟄蟈蟎蟣蟬" +
+		"蟯蟲蟶蟻蠅蠆蠍蠐蠑蠔蠟蠣蠨蠱蠶蠻衆衊術衛衝衹袞裏補裝裡製複褌褘褲褳褸褻襇襏襖襝襠襤襪襬襯襲見覎規覓視" +
+		"覘覡覥覦親覬覯覲覷覺覽覿觀觴觶觸訁訂訃計訊訌討訐訓訕訖託記訛訝訟訣訥訩訪設許訴訶診証詁詆詎詐詒詔評詖" +
+		"詗詘詛詞詠詡詢詣試詩詫詬詭詮詰話該詳詵詼詿誄誅誆誇誌認誑誒誕誘誚語誠誡誣誤誥誦誨說説誰課誶誹誼調諂諄" +
+		"談諉請諍諏諑諒論諗諛諜諞諢諤諦諧諫諭諮諱諳諶諷諸諺諼諾謀謁謂謄謅謊謎謐謔謖謗謙謚講謝謠謨謫謬謳謹謾證" +
+		"譎譏譖識譙譚譜譫譯議譴護譸譽譾讀變讋讎讒讓讕讖讚讜讞豈豎豐豔豬豶貓貝貞負財貢貧貨販貪貫責貯貰貲貳貴貶" +
+		"買貸貺費貼貽貿賀賁賂賃賄賅資賈賊賑賒賓賕賙賚賜賞賠賡賢賣賤賦賧質賬賭賴賵賺賻購賽賾贄贅贇贈贊贍贏贐贓" +
+		"贔贖贗贛趕趙趨趲跡踐踴蹌蹕蹟蹠蹣蹤蹺躂躉躊躋躍躑躒躓躕躚躡躥躦躪軀車軋軌軍軒軔軛軟軤軫軲軸軹軺軻軼軾" +
+		"較輅輇載輊輒輔輕輛輜輝輞輟輥輦輩輪輬輯輳輸輻輾轀轂轄轅轆轉轍轎轔轟轡轢轤辦辭辯農迴逕這連週進遊運過達" +
+		"違遙遜遞遠適遲遷選遺遼邁還邇邊邏邐郟郵鄆鄉鄒鄖鄧鄭鄰鄲鄴鄶鄺酈醃醜醞醫醬醱釀釁釃釅釋釐釓釔釕釗釘釙針" +
+		"釣釤釧釩釵釷釹釺鈀鈁鈄鈈鈉鈍鈐鈑鈔鈕鈞鈣鈥鈦鈧鈮鈰鈳鈴鈷鈸鈹鈺鈽鈾鈿鉀鉈鉉鉍鉑鉕鉗鉚鉛鉞鉢鉤鉦鉬鉭鉸" +
+		"鉺鉻鉿銀銃銅銑銓銖銘銚銜銠銣銥銦銨銩銪銫銬銳銷銹銻銼鋁鋃鋅鋇鋌鋏鋒鋝鋟鋣鋤鋥鋦鋨鋪鋮鋯鋰鋱鋶鋸鋼錁錄" +
+		"錆錇錈錐錒錕錘錙錚錛錟錠錡錢錦錨錩錫錮錯錳錶錸鍁鍃鍆鍇鍈鍊鍋鍍鍔鍘鍚鍛鍠鍤鍥鍬鍰鍵鍶鍺鍾鎂鎄鎇鎊鎖鎘" +
+		"鎛鎢鎣鎦鎧鎩鎪鎬鎮鎰鎳鎵鎿鏃鏇鏈鏌鏍鏐鏑鏗鏘鏜鏝鏞鏟鏡鏢鏤鏨鏰鏵鏷鏹鏽鐃鐋鐐鐒鐓鐔鐘鐙鐠鐦鐧鐨鐫鐮鐲" +
+		"鐳鐵鐶鐸鐺鐿鑄鑊鑌鑑鑒鑔鑠鑣鑥鑪鑭鑰鑲鑷鑹鑼鑽鑾鑿長門閂閃閆閈閉開閌閎閏閑閒間閔閘閡閣閥閨閩閫閬閭閱" +
+		"閶閹閻閼閽閾閿闃闆闈闊闋闌闍闐闒闓闔闕闖關闞闠闡闥陘陝陣陰陳陸陽隉隊階隕際隨險隱隴隸隻雋雖雙雛雜雞離" +
+		"難雲電霧霽靂靄靈靚靜靦靨鞏鞽韃韉韋韌韓韙韜韞韻響頁頂頃項順須頊頌頎頏預頑頒頓頗領頜頡頤頦頭頰頷頸頹頻" +
+		"顆題額顏顒願顙顛類顢顧顫顯顰顱顳顴風颮颯颱颶颸颺颼飄飆飛飢飣飩飪飫飭飯飲飴飼飽飾餃餅餉養餌餑餒餓餘餛" +
+		"餞餡館餱餳餵餶餷餺餾餿饃饅饈饉饊饋饌饒饗饜饞饢馬馭馮馱馳馴駁駐駑駒駔駕駘駙駛駝駟駡駢駭駱駿騁騅騍騎騏" +
+		"騙騰騶騷騾驀驁驂驃驄驅驍驗驚驛驟驢驤驥驪髏髒體髕髖髮鬆鬍鬚鬢鬥鬧鬩鬮鬱魎魘魚魯魷鮑鮪鮭鮮鯉鯊鯖鯛鯧鯨" +
+		"鯽鰍鰱鰻鱈鱉鱒鱔鱗鱷鱸鱺鳥鳧鳩鳳鳴鴉鴛鴦鴨鴻鴿鵑鵝鵡鵪鵬鵰鵲鶉鶯鶴鷄鷗鷲鷹鷺鸚鸞鹵鹹鹼鹽麗麥麩麪麵麼" +
+		"黃黌黙點黨黴黶黷黽黿鼴齊齋齎齒齡齣齦齧齶龍龐龔龕龜"
+	t2sTo = "" +
+		"并乱亚伫占来仑侣俣系侠伥俩仓个们伦伟侧侦伪杰伧伞备家佣传伛债伤倾偻仅佥侨仆伪侥偾价仪侬亿侩俭傧俦侪尽" +
+		"偿优储俪傩傥俨兑儿兖内两册幂冻凛凯别删刭则刬刚剥剐剀创划剧刘刽刿剑剂劲动务胜劳势勚劢勋励劝匀匦汇匮区" +
+		"协却即厍厌厉厣参丛吴吕呙员呗念问启哑启唤丧吃乔单哟呛啬吗呜唢哔叹喽呕啧尝唛哗唠啸叽哓呒恶嘘咝哒哝哕嗳" +
+		"哙喷吨咛吓哜尝噜呖咙向喾严嘤啭嗫冁呓啰嘱囱囵国围园圆图团垭执坚垩埚尧报场块茔垲埘冢坞埙尘堑垫坠堕坟垦" +
+		"坛压垒圹垆坏垄垅坜坝塆壮壶寿够梦伙夹奂奥奁夺奋妆你姗娱娄妇娅娲妫媪妈袅妪妩娴婳娆婵娇嫱嫒嬷嫔婴婶娈孙" +
+		"学孪宫寝实宁审写宽宠宝将专寻对导尴届尸屉屡层屦属冈峰岘岛峡崃岗峥岽岚崭岖嵚崂峤峣峄崄嵘岭屿岳岿峦巅巯" +
+		"卺帅师帐带帧帏帼帻帜币帮帱干几厕厢厩厦庼厨厮厂废广廪庐厅张强弹弥弯汇彦雕后径从徕征彻恒耻悦怅闷恶恼恽" +
+		"恻爱惬怆恺忾态愠惨惭恸惯怄怂虑悭庆忧惫怜凭愦惮愤悯怃宪忆恳应怿怼懑恹惩懒怀悬忏惧慑恋戆戋戗戬战戏户抛" +
+		"挟舍扪扫抡挣挂拣扬换挥损摇捣抢榨掴掼搂挚抠抟掺捞挦撑挠挢掸拨抚扑揿挞挝捡拥掳择击挡担据挤捣拟摈拧搁掷" +
+		"扩撷摆擞撸扰摅撵拢拦撄搀撺携摄攒挛摊搅揽教败叙敌数敛毙斓斩断于既时晋昼晕晖旸畅暂晔历昙晓暧旷晒书会胧" +
+		"术东栅栀枧条枭弃枨枣栋栈栖桠杨枫桢业极干杩荣桤构枪椠椁概桨椝桩乐枞楼标枢样朴树桦桡桥机椭横檩柽档桧槚" +
+		"检樯台槟柠槛柜橹榈栉椟橼栎橱槠栌枥橥榇栊榉樱栏权椤栾榄棂钦欧欤欢岁历归殁残殒殇殚殓殡歼杀壳毁殴毵毡气" +
+		"氢氩氲决没况汹浃泾凉凄泪净沦渊涞浅涣减涡测浑凑浈汤沩准沟温湿沧灭涤荥沪滞渗浒滚满渔沤汉涟渍涨溆渐浆颍" +
+		"泼洁潜润浔溃涠涩浇涝涧渑泽滪泶浍浊浓湿泞济涛滥潍滨溅泺滤渎泻渖浏濒泸沥潇潆泷濑潋澜沣洒滩灏湾滦灾为乌" +
+		"烃无炼炜烟茕焕烦炀荧炝热炽烨灯炖烧烫焖营灿毁烛烩烬焘烁炉烂争为爷尔墙牍它牵荦牦犊牺状狭狈狰犹狲犸呆狱" +
+		"狮奖独狯猃狝狞获猎犷兽獭献猕猡现珐珲玮琐瑶莹玛琅琏玑珰环玺琼珑璎瓒瓯产亩毕画异当畴叠痉酸疴痖疯疡痪瘗" +
+		"疮疟瘆瘘疗痨痫瘅疠瘪痴痒疖癞癣瘿瘾痈瘫癫发皑疱皲皱杯盗盏尽监盘卢真眦众困眍瞒睑瞩矫炮朱硁硖砗砚硕砀砜" +
+		"确码硙砖碜碛矶硗础碍矿砺砾矾砻秘禄祸祯祎禅礼祢秃籼税秆种称谷积颖秾秽稳获窝洼穷窑窥窜窍窦窃竞笔笋笕笺" +
+		"筝节范筑笃筛筚箦篓箪简篑箫签帘篮筹箓箨笼签笾篱箩吁粤糁粪粮粝籴粜纠纪纣约红纡纥纨纫纹纳纽纾纯纰纼纱纸" +
+		"级纷纭纺扎细绂绁绅绍绀绋绐绌终组绊绗结绝绦绞络绚给绒绖统丝绛绝绢绑绡绠绨绤绥捆经综缍绿绸绻线绶维绾纲" +
+		"网缀纶绺绮绽绰绫绵绲缁紧绯绪缃缄缂线缉缎缔缗缘缌编缓缅纬缑缈练缏缇致萦缙缢缒绉缣缚缜缟缛县缝缡缩纵缧" +
+		"纤缦絷缕缥总绩绷缫缪织缮缭绕绣绳绘系茧缳缴绎继缤缬纩续累缠缨才纤缵缆钵坛罂罚骂罢罗罴羁芈羟义习翘耧圣" +
+		"闻联聪声耸聩聂职聍听聋肃胁脉胫脱胀肾脶脑肿脚肠腽腘肤胶腻胆脍脓脸脐膑腊胪脏脔卧临台与兴举旧铺舱舰舻艰" +
+		"刍苎兹庄茎荚苋华苌莱万莴叶荭荮苇荤搜莳莅苍荪盖莜莲苁莼荜卜蒌蒋葱茑荫荨蒇荞荬芸莸荛蒉荡芜萧蓣荟蓟芗姜" +
+		"蔷荙莶荐萨熏荠蓝荩艺药薮苈蔼蔺萚蕲芦苏蕴苹藓蔹茏兰蓠萝处虚虏号亏虬蛱蜕蚬蚀猬虾蜗蛳蚂萤蝼螀蛰蝈螨虮蝉" +
+		"蛲虫蛏蚁蝇虿蝎蛴蝾蚝蜡蛎蟏蛊蚕蛮众蔑术卫冲只衮里补装里制复裈袆裤裢褛亵裥袯袄裣裆褴袜摆衬袭见觃规觅视" +
+		"觇觋觍觎亲觊觏觐觑觉览觌观觞觯触讠订讣计讯讧讨讦训讪讫托记讹讶讼诀讷讻访设许诉诃诊证诂诋讵诈诒诏评诐" +
+		"诇诎诅词咏诩询诣试诗诧诟诡诠诘话该详诜诙诖诔诛诓夸志认诳诶诞诱诮语诚诫诬误诰诵诲说说谁课谇诽谊调谄谆" +
+		"谈诿请诤诹诼谅论谂谀谍谝诨谔谛谐谏谕谘讳谙谌讽诸谚谖诺谋谒谓誊诌谎谜谧谑谡谤谦谥讲谢谣谟谪谬讴谨谩证" +
+		"谲讥谮识谯谭谱谵译议谴护诪誉谫读变詟雠谗让谰谶赞谠谳岂竖丰艳猪豮猫贝贞负财贡贫货贩贪贯责贮贳赀贰贵贬" +
+		"买贷贶费贴贻贸贺贲赂赁贿赅资贾贼赈赊宾赇赒赉赐赏赔赓贤卖贱赋赕质账赌赖赗赚赙购赛赜贽赘赟赠赞赡赢赆赃" +
+		"赑赎赝赣赶赵趋趱迹践踊跄跸迹跖蹒踪跷跶趸踌跻跃踯跞踬蹰跹蹑蹿躜躏躯车轧轨军轩轫轭软轷轸轱轴轵轺轲轶轼" +
+		"较辂辁载轾辄辅轻辆辎辉辋辍辊辇辈轮辌辑辏输辐辗辒毂辖辕辘转辙轿辚轰辔轹轳办辞辩农回迳这连周进游运过达" +
+		"违遥逊递远适迟迁选遗辽迈还迩边逻逦郏邮郓乡邹郧邓郑邻郸邺郐邝郦腌丑酝医酱酦酿衅酾酽释厘钆钇钌钊钉钋针" +
+		"钓钐钏钒钗钍钕钎钯钫钭钚钠钝钤钣钞钮钧钙钬钛钪铌铈钶铃钴钹铍钰钸铀钿钾铊铉铋铂钷钳铆铅钺钵钩钲钼钽铰" +
+		"铒铬铪银铳铜铣铨铢铭铫衔铑铷铱铟铵铥铕铯铐锐销锈锑锉铝锒锌钡铤铗锋锊锓铘锄锃锔锇铺铖锆锂铽锍锯钢锞录" +
+		"锖锫锩锥锕锟锤锱铮锛锬锭锜钱锦锚锠锡锢错锰表铼锨锪钔锴锳炼锅镀锷铡钖锻锽锸锲锹锾键锶锗钟镁锿镅镑锁镉" +
+		"镈钨蓥镏铠铩锼镐镇镒镍镓镎镞镟链镆镙镠镝铿锵镗镘镛铲镜镖镂錾镚铧镤镪锈铙铴镣铹镦镡钟镫镨锎锏镄镌镰镯" +
+		"镭铁镮铎铛镱铸镬镔鉴鉴镲铄镳镥炉镧钥镶镊镩锣钻銮凿长门闩闪闫闬闭开闶闳闰闲闲间闵闸阂阁阀闺闽阃阆闾阅" +
+		"阊阉阎阏阍阈阌阒板闱阔阕阑阇阗阘闿阖阙闯关阚阓阐闼陉陕阵阴陈陆阳陧队阶陨际随险隐陇隶只隽虽双雏杂鸡离" +
+		"难云电雾霁雳霭灵靓静腼靥巩鞒鞑鞯韦韧韩韪韬韫韵响页顶顷项顺须顼颂颀颃预顽颁顿颇领颌颉颐颏头颊颔颈颓频" +
+		"颗题额颜颙愿颡颠类颟顾颤显颦颅颞颧风飑飒台飓飔飏飕飘飙飞饥饤饨饪饫饬饭饮饴饲饱饰饺饼饷养饵饽馁饿余馄" +
+		"饯馅馆糇饧喂馉馇馎馏馊馍馒馐馑馓馈馔饶飨餍馋馕马驭冯驮驰驯驳驻驽驹驵驾骀驸驶驼驷骂骈骇骆骏骋骓骒骑骐" +
+		"骗腾驺骚骡蓦骜骖骠骢驱骁验惊驿骤驴骧骥骊髅脏体髌髋发松胡须鬓斗闹阋阄郁魉魇鱼鲁鱿鲍鲔鲑鲜鲤鲨鲭鲷鲳鲸" +
+		"鲫鳅鲢鳗鳕鳖鳟鳝鳞鳄鲈鲡鸟凫鸠凤鸣鸦鸳鸯鸭鸿鸽鹃鹅鹉鹌鹏雕鹊鹑莺鹤鸡鸥鹫鹰鹭鹦鸾卤咸碱盐丽麦麸面面么" +
+		"黄黉默点党霉黡黩黾鼋鼹齐斋赍齿龄出龈啮腭龙庞龚龛龟"
+)
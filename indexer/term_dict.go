@@ -0,0 +1,245 @@
+package indexer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-ego/riot/types"
+)
+
+const (
+	// 前缀、通配符、模糊查询最多扩展出的查询词个数
+	MaxTermExpansions = 64
+	// 模糊查询最大的编辑距离
+	MaxFuzzyDistance = 2
+)
+
+// 索引库的词典: 字段序号 -> 该字段中出现过的所有token
+// 删除doc时不会从词典中删除token，多出来的token在扩展查询时只是匹配不到doc
+type termDict struct {
+	lock     sync.RWMutex
+	fields   map[int]map[string]struct{}
+	sorted   map[int][]string // 排好序的token，用于前缀查找，有新token时失效
+	loadOnce sync.Once
+}
+
+func newTermDict() *termDict {
+	return &termDict{
+		fields: map[int]map[string]struct{}{},
+		sorted: map[int][]string{},
+	}
+}
+
+func (d *termDict) add(fIdx int, tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	terms, ok := d.fields[fIdx]
+	if !ok {
+		terms = map[string]struct{}{}
+		d.fields[fIdx] = terms
+	}
+	for _, token := range tokens {
+		if _, ok := terms[token]; !ok {
+			terms[token] = struct{}{}
+			delete(d.sorted, fIdx)
+		}
+	}
+}
+
+func (d *termDict) fieldTerms(fIdx int) []string {
+	d.lock.RLock()
+	terms, ok := d.sorted[fIdx]
+	d.lock.RUnlock()
+	if ok {
+		return terms
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	terms = make([]string, 0, len(d.fields[fIdx]))
+	for term := range d.fields[fIdx] {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	d.sorted[fIdx] = terms
+	return terms
+}
+
+func (d *termDict) fieldIdxs() []int {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	res := make([]int, 0, len(d.fields))
+	for fIdx := range d.fields {
+		res = append(res, fIdx)
+	}
+	sort.Ints(res)
+	return res
+}
+
+// 查找词典中匹配查询词的token，fIdx<0表示在所有字段中查找
+func (d *termDict) expand(fIdx int, t *termNode) []string {
+	var fIdxs []int
+	if fIdx >= 0 {
+		fIdxs = []int{fIdx}
+	} else {
+		fIdxs = d.fieldIdxs()
+	}
+
+	found := map[string]int{} // token -> 编辑距离
+	for _, i := range fIdxs {
+		terms := d.fieldTerms(i)
+		switch t.kind {
+		case termPrefix:
+			prefix := t.text[:len(t.text)-1]
+			for j := sort.SearchStrings(terms, prefix); j < len(terms) && strings.HasPrefix(terms[j], prefix); j++ {
+				found[terms[j]] = 0
+				if len(found) >= MaxTermExpansions {
+					break
+				}
+			}
+		case termWildcard:
+			pattern := []rune(t.text)
+			for _, term := range terms {
+				if wildcardMatch(pattern, []rune(term)) {
+					found[term] = 0
+					if len(found) >= MaxTermExpansions {
+						break
+					}
+				}
+			}
+		case termFuzzy:
+			word := []rune(t.text)
+			for _, term := range terms {
+				if dist := editDistance(word, []rune(term), t.fuzzy); dist <= t.fuzzy {
+					found[term] = dist
+				}
+			}
+		}
+	}
+
+	res := make([]string, 0, len(found))
+	for term := range found {
+		res = append(res, term)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if found[res[i]] != found[res[j]] {
+			return found[res[i]] < found[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	if len(res) > MaxTermExpansions {
+		res = res[:MaxTermExpansions]
+	}
+	return res
+}
+
+// 通配符匹配，'*'匹配任意个字符，'?'匹配一个字符
+func wildcardMatch(pattern, s []rune) bool {
+	px, sx := 0, 0
+	starPx, starSx := -1, -1
+	for sx < len(s) {
+		switch {
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == s[sx]):
+			px++
+			sx++
+		case px < len(pattern) && pattern[px] == '*':
+			starPx, starSx = px, sx
+			px++
+		case starPx >= 0:
+			px = starPx + 1
+			starSx++
+			sx = starSx
+		default:
+			return false
+		}
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// 计算编辑距离(相邻字符交换算一次编辑)，超过max时返回max+1
+func editDistance(a, b []rune, max int) int {
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(minInt(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				curr[j] = minInt(curr[j], prev2[j-2]+1)
+			}
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// 加载索引库时词典是空的，第一次使用时从已经保存的doc中生成词典
+func (idx *indexer) loadTermDict() {
+	idx.dict.loadOnce.Do(func() {
+		idx.engine.Search(types.SearchReq{
+			Labels: allDocs,
+			Tokens: allDocs,
+			RankOpts: &types.RankOpts{
+				ScoringCriteria: &dictLoader{idx: idx},
+			},
+		})
+	})
+}
+
+// 遍历所有doc生成词典，必须实现types.ScoringCriteria
+type dictLoader struct {
+	idx *indexer
+}
+
+func (loader *dictLoader) Score(doc types.IndexedDoc, fields interface{}) []float32 {
+	if storedDoc, ok := fields.(StoredDoc); ok {
+		schema := loader.idx.schema
+		for fieldName, v := range storedDoc {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			fIdx, ok := schema.FieldMap[fieldName]
+			if !ok {
+				continue
+			}
+			if analyzer := fieldAnalyzer(schema, fIdx); analyzer != nil {
+				loader.idx.dict.add(fIdx, analyzer.Analyze(s))
+			}
+		}
+	}
+	return []float32{}
+}
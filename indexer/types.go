@@ -1,7 +1,6 @@
 package indexer
 
 import (
-	"go-search/conf"
 	"sync"
 
 	"github.com/go-ego/riot"
@@ -9,8 +8,46 @@ import (
 
 // 索引库: 一个索引schema定义 + 一个搜索引擎实例
 type indexer struct {
-	schema *conf.Schema
+	schema *indexSchema
 	engine *riot.Engine
+	dict   *termDict
+	docs   *docMap
+
+	updateLock sync.Mutex // 写入、删除、修改doc的操作互斥，写入生效后才解锁，读出、修改、写回期间不会被其它写入覆盖
+
+	synonyms    *synonymSet // 查询时使用的同义词，可以随时替换
+	synonymLock sync.RWMutex
+}
+
+// 搜索参数，与/search/:index的query参数对应
+type QueryArgs struct {
+	Q          string
+	Fq         string
+	S          string
+	F          string
+	Page       string
+	PageSize   string
+	Fl         string
+	Facet      string
+	FacetSize  string
+	Agg        string
+	Hl         string
+	HlPre      string
+	HlPost     string
+	HlFragSize string // 片段长度
+	HlSnippets string // 每个字段的片段数
+	Explain    bool
+	After      string // search_after游标
+	HasAfter   bool   // 有after参数时使用游标分页，after为空时从第一个结果开始
+}
+
+// 搜索结果
+type QueryResult struct {
+	Pagination interface{}
+	Timeout    bool
+	Facets     map[string][]FacetValue // 没有facet参数时为nil
+	Aggs       map[string]interface{}  // 没有agg参数时为nil
+	Docs       <-chan interface{}      // 当前页的doc
 }
 
 // q
@@ -24,7 +61,8 @@ type query struct {
 type sorting struct {
 	fieldName string
 	asc       bool
-	fIdx      int // set when querying
+	collation string // 字符串的排序规则
+	fIdx      int    // set when querying
 }
 
 // filter range
@@ -44,17 +82,28 @@ type filter struct {
 type fquery struct {
 	fieldName string
 	*query
+	expr qNode // fq的语法树，会并入q的语法树
 }
 
 type parsedQuery struct {
 	*query
-	labels       []string
+	expr         qNode // q的语法树，只有不能转换为query时才会用到
 	fquerys      []fquery
 	sortBys      []sorting
 	filters      []filter
 	start        int
 	rows         int
 	outFieldList []string
+	facetFields  []string
+	facetSize    int
+	aggs         []aggSpec
+	hl           *highlight // 没有hl参数时为nil
+	explain      bool
+	sortAll      bool // 需要取出所有结果排序后再分页
+	useCursor    bool
+	after        string
+	cursor       *searchCursor // set when querying
+	nextCursor   string        // 下一页的游标
 }
 
 // 保存的字段，既用于显示，又用于过滤、打分
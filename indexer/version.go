@@ -0,0 +1,94 @@
+package indexer
+
+import (
+	"fmt"
+	"go-search/conf"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 保存在doc中的版本号字段，每次写入doc时更新
+const VersionField = "_version"
+
+// if_version与doc的当前版本不一致
+var ErrVersionConflict = fmt.Errorf("version conflict")
+
+// 每次持久化时预留的版本号个数
+const versionLease = 1000000
+
+// 最近生成的版本号，用启动时间(微秒)和持久化的上限中较大的初始化，时钟回拨后重启也不会重复
+// 不用纳秒是为了不超过2^53，JSON客户端可以精确表示
+var (
+	lastVersion  = uint64(time.Now().UnixNano() / 1000)
+	versionLimit uint64 // 已经持久化的上限，超过时先持久化新的上限
+	versionFile  string // 为空时不持久化，如测试时
+	versionLock  sync.Mutex
+)
+
+// 从root-dir中读取持久化的版本号上限，服务启动时调用
+func InitVersion() error {
+	f := path.Join(conf.ServiceConf.RootDir, ".version")
+	b, err := ioutil.ReadFile(f)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	versionLock.Lock()
+	defer versionLock.Unlock()
+	if err == nil {
+		limit, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("bad version file %s: %v", f, err)
+		}
+		if limit > lastVersion {
+			lastVersion = limit
+		}
+	}
+	versionFile, versionLimit = f, lastVersion
+	return nil
+}
+
+// 生成新的版本号，版本号单调递增，但不连续。上限无法持久化时返回错误
+func nextVersion() (uint64, error) {
+	versionLock.Lock()
+	defer versionLock.Unlock()
+
+	version := lastVersion + 1
+	if versionFile != "" && version > versionLimit {
+		limit := version + versionLease
+		if err := saveVersionLimit(limit); err != nil {
+			return 0, fmt.Errorf("failed to save version: %v", err)
+		}
+		versionLimit = limit
+	}
+	lastVersion = version
+	return version, nil
+}
+
+// 先写临时文件再改名，避免写到一半时退出
+func saveVersionLimit(limit uint64) error {
+	tmp := versionFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatUint(limit, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, versionFile)
+}
+
+// doc的当前版本号，doc不存在时为0
+func docVersion(doc map[string]interface{}) uint64 {
+	version, _ := doc[VersionField].(uint64)
+	return version
+}
+
+// 检查doc的当前版本，ifVersion为nil时不检查，为0时要求doc不存在
+func checkVersion(doc map[string]interface{}, ifVersion *uint64) error {
+	if ifVersion != nil && docVersion(doc) != *ifVersion {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package indexer
+
+import (
+	"go-search/conf"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func Test_checkVersion(t *testing.T) {
+	doc := map[string]interface{}{"id": 1, VersionField: uint64(10)}
+	v := func(n uint64) *uint64 { return &n }
+
+	cases := []struct {
+		doc       map[string]interface{}
+		ifVersion *uint64
+		err       error
+	}{
+		{doc, nil, nil},
+		{nil, nil, nil},
+		{doc, v(10), nil},
+		{doc, v(9), ErrVersionConflict},
+		{doc, v(0), ErrVersionConflict}, // 0要求doc不存在
+		{nil, v(0), nil},
+		{nil, v(10), ErrVersionConflict},
+	}
+	for i, c := range cases {
+		if err := checkVersion(c.doc, c.ifVersion); err != c.err {
+			t.Errorf("case #%d: expected %v, got %v", i, c.err, err)
+		}
+	}
+}
+
+func Test_nextVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	savedRoot, savedLast := conf.ServiceConf.RootDir, lastVersion
+	defer func() {
+		conf.ServiceConf.RootDir, lastVersion = savedRoot, savedLast
+		versionFile, versionLimit = "", 0
+	}()
+
+	// 持久化的上限比当前时间大，如时钟回拨后重启
+	f := filepath.Join(dir, ".version")
+	future := lastVersion + 100*versionLease
+	if err = ioutil.WriteFile(f, []byte(strconv.FormatUint(future, 10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf.ServiceConf.RootDir = dir
+	if err = InitVersion(); err != nil {
+		t.Fatal(err)
+	}
+
+	v1, err := nextVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, _ := nextVersion()
+	if v1 <= future || v2 <= v1 {
+		t.Errorf("versions %d, %d should be greater than %d and increasing", v1, v2, future)
+	}
+	b, _ := ioutil.ReadFile(f)
+	if limit, _ := strconv.ParseUint(string(b), 10, 64); limit < v2 {
+		t.Errorf("saved limit %d should not be less than issued version %d", limit, v2)
+	}
+
+	// 重启后从保存的上限继续
+	lastVersion = 0
+	if err = InitVersion(); err != nil {
+		t.Fatal(err)
+	}
+	if v3, _ := nextVersion(); v3 <= v2 {
+		t.Errorf("version %d after restart should be greater than %d", v3, v2)
+	}
+}
@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"go-search/indexer"
+	"log"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// POST /bulk
+//
+// 批量执行多个索引库的增加、修改、删除，所有操作完成后每个索引库只flush一次
+//
+// POST body: NDJSON，每行一个操作
+// {"index": "index-name", "op": "index", "doc": {...}}
+// {"index": "index-name", "op": "update", "doc": {"id": 1, "$inc": {"views": 1}}, "if_version": N}
+// {"index": "index-name", "op": "upsert", "doc": {"id": 2, "name": "xxx"}}
+// {"index": "index-name", "op": "delete", "id": "docId"}
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "OK",
+//   "errors": false,  // 是否有失败的操作
+//   "items": [
+//      {"index": "index-name", "op": "index", "id": "docId", "version": N, "status": 200},
+//      {"index": "index-name", "op": "update", "id": "docId", "status": 409, "error": "version conflict"},
+//      ...
+//   ]
+// }
+func Bulk(c *helper.Context) {
+	log.Printf("[bulk] %s\n", c.Request().RequestURI)
+
+	body := c.Request().Body
+	defer body.Close()
+	items, err := indexer.Bulk(body)
+	if err != nil {
+		_ = c.Error(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	hasError := false
+	for i := range items {
+		if items[i].Status != http.StatusOK {
+			hasError = true
+			break
+		}
+	}
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":   http.StatusOK,
+		"msg":    "OK",
+		"errors": hasError,
+		"items":  items,
+	})
+}
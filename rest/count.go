@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"go-search/indexer"
+	"log"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// GET /count/:index?q=+xxx&fq=f:q-in-field&f=f1:xxx|f2:r1~r2
+//
+// 统计满足条件的doc数，不排序、不输出doc
+//
+// query arguments:
+//  q, fq, f: 同/search/:index，不合法时返回400
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "OK",
+//   "total": 5
+// }
+func Count(c *helper.Context) {
+	log.Printf("[count] %s\n", c.Request().RequestURI)
+	index := c.Param("index")
+
+	args := &indexer.QueryArgs{
+		Q:  c.QueryParam("q"),
+		Fq: c.QueryParam("fq"),
+		F:  c.QueryParam("f"),
+	}
+	total, err := indexer.Count(index, args)
+	if err != nil {
+		_ = c.Error(errorStatus(err), err.Error())
+		return
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":  http.StatusOK,
+		"msg":   "OK",
+		"total": total,
+	})
+}
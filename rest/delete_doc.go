@@ -2,12 +2,15 @@ package rest
 
 import (
 	"go-search/indexer"
+	"log"
 	"net/http"
 
 	helper "github.com/rosbit/http-helper"
 )
 
-// DELETE /doc/:index
+// DELETE /doc/:index[?if_version=N]
+//
+// with if_version, the current version of the document must be N, or 409 is returned.
 //
 // POST body:
 // {
@@ -22,8 +25,13 @@ func DeleteDoc(c *helper.Context) {
 		_ = c.Error(code, err.Error())
 		return
 	}
-	if err := indexer.DeleteDoc(index, doc.ID); err != nil {
-		_ = c.Error(http.StatusInternalServerError, err.Error())
+	ifVersion, err := getIfVersion(c)
+	if err != nil {
+		_ = c.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := indexer.DeleteDoc(index, doc.ID, ifVersion); err != nil {
+		_ = c.Error(errorStatus(err), err.Error())
 		return
 	}
 
@@ -60,3 +68,48 @@ func DeleteDocs(c *helper.Context) {
 		"ids":  docIds,
 	})
 }
+
+// POST /delete_by_query/:index?q=+xxx&fq=f:q-in-field&f=f1:xxx|f2:r1~r2&dryrun
+//
+// 删除所有满足条件的doc
+//
+// query arguments:
+//  q, fq, f: 同/search/:index，q=*时匹配所有doc，没有任何条件或条件不合法时返回400
+//  all: 为true时相当于q=*
+//  dryrun: 只统计满足条件的doc数，不删除
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "docs removed from index",
+//   "deleted": 5
+// }
+func DeleteByQuery(c *helper.Context) {
+	log.Printf("[delete_by_query] %s\n", c.Request().RequestURI)
+	index := c.Param("index")
+
+	args := &indexer.QueryArgs{
+		Q:  c.QueryParam("q"),
+		Fq: c.QueryParam("fq"),
+		F:  c.QueryParam("f"),
+	}
+	if c.QueryParam("all") == "true" && args.Q == "" {
+		args.Q = indexer.MatchAll
+	}
+	_, dryRun := c.QueryParams()["dryrun"]
+	deleted, err := indexer.DeleteByQuery(index, args, dryRun)
+	if err != nil {
+		_ = c.Error(errorStatus(err), err.Error())
+		return
+	}
+
+	msg := "docs removed from index"
+	if dryRun {
+		msg = "dry run, no doc removed"
+	}
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":    http.StatusOK,
+		"msg":     msg,
+		"deleted": deleted,
+	})
+}
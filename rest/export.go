@@ -0,0 +1,107 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"go-search/indexer"
+	"log"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// 每输出多少个doc刷新一次
+const exportFlushCount = 1000
+
+// GET /export/:index?q=+xxx&fq=f:q-in-field&f=f1:xxx|f2:r1~r2&fl=f1,f2&format=jsonl|csv
+//
+// 导出所有满足条件的doc，不分页、不排序
+//
+// query arguments:
+//  q, fq, f, fl: 同/search/:index，不合法时返回400
+//  format: 输出格式，jsonl: 每行一个JSON doc(缺省)，csv: 第一行为字段名
+//
+// 返回结果: 按format格式逐个输出doc
+func Export(c *helper.Context) {
+	log.Printf("[export] %s\n", c.Request().RequestURI)
+	index := c.Param("index")
+
+	format := c.QueryParam("format")
+	switch format {
+	case "":
+		format = "jsonl"
+	case "jsonl", "csv":
+	default:
+		_ = c.Error(http.StatusBadRequest, "format must be jsonl or csv")
+		return
+	}
+
+	args := &indexer.QueryArgs{
+		Q:  c.QueryParam("q"),
+		Fq: c.QueryParam("fq"),
+		F:  c.QueryParam("f"),
+		Fl: c.QueryParam("fl"),
+	}
+	fields, docs, err := indexer.Export(index, args, c.Request().Context().Done())
+	if err != nil {
+		_ = c.Error(errorStatus(err), err.Error())
+		return
+	}
+
+	w := c.Response()
+	flusher, _ := w.(http.Flusher)
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		outputCSVDocByDoc(w, flusher, fields, docs)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		outputJSONLDocByDoc(w, flusher, docs)
+	}
+}
+
+func outputJSONLDocByDoc(w http.ResponseWriter, flusher http.Flusher, docs <-chan interface{}) {
+	je := json.NewEncoder(w)
+	je.SetEscapeHTML(false)
+
+	count := 0
+	for doc := range docs {
+		_ = je.Encode(doc)
+		count++
+		if flusher != nil && count%exportFlushCount == 0 {
+			flusher.Flush()
+		}
+	}
+}
+
+func outputCSVDocByDoc(w http.ResponseWriter, flusher http.Flusher, fields []string, docs <-chan interface{}) {
+	cw := csv.NewWriter(w)
+	_ = cw.Write(fields)
+
+	count := 0
+	row := make([]string, len(fields))
+	for doc := range docs {
+		d, _ := doc.(indexer.StoredDoc)
+		for i, f := range fields {
+			switch v := d[f].(type) {
+			case nil:
+				row[i] = ""
+			case string:
+				row[i] = v
+			default:
+				b, _ := json.Marshal(v)
+				row[i] = string(b)
+			}
+		}
+		_ = cw.Write(row)
+		count++
+		if count%exportFlushCount == 0 {
+			cw.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	cw.Flush()
+}
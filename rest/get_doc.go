@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"fmt"
+	"go-search/indexer"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// GET /doc/:index/:id[?fl=f1,f2]
+//
+// 根据docID获取doc，docID为PK字段的值，多个PK字段的值用'_'连接
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "OK",
+//   "doc": {...}
+// }
+func GetDoc(c *helper.Context) {
+	index := c.Param("index")
+	docId := c.Param("id")
+
+	docs, err := indexer.GetDocs(index, []string{docId}, c.QueryParam("fl"))
+	if err != nil {
+		_ = c.Error(getDocsStatus(err), err.Error())
+		return
+	}
+	doc, ok := docs[docId]
+	if !ok {
+		_ = c.Error(http.StatusNotFound, "doc not found")
+		return
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code": http.StatusOK,
+		"msg":  "OK",
+		"doc":  doc,
+	})
+}
+
+// HEAD /doc/:index/:id
+//
+// doc是否存在，存在时返回200，否则返回404
+func HasDoc(c *helper.Context) {
+	index := c.Param("index")
+	docId := c.Param("id")
+
+	docs, err := indexer.GetDocs(index, []string{docId}, "")
+	if err != nil {
+		c.Response().WriteHeader(getDocsStatus(err))
+		return
+	}
+	if _, ok := docs[docId]; !ok {
+		c.Response().WriteHeader(http.StatusNotFound)
+		return
+	}
+	c.Response().WriteHeader(http.StatusOK)
+}
+
+// POST /mget/:index[?fl=f1,f2]
+//
+// 根据多个docID获取doc
+//
+// POST body:
+// [
+//    docId1, docId2, ...
+// ]
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "OK",
+//   "docs": [{doc1}, null, ...]  // 与docID的顺序一致，不存在的doc为null
+// }
+func MultiGetDocs(c *helper.Context) {
+	index := c.Param("index")
+
+	var ids []interface{}
+	if code, err := c.ReadJSON(&ids); err != nil {
+		_ = c.Error(code, err.Error())
+		return
+	}
+	docIds := make([]string, len(ids))
+	for i, id := range ids {
+		docIds[i] = fmt.Sprintf("%v", id)
+	}
+
+	docs, err := indexer.GetDocs(index, docIds, c.QueryParam("fl"))
+	if err != nil {
+		_ = c.Error(getDocsStatus(err), err.Error())
+		return
+	}
+	res := make([]interface{}, len(docIds))
+	for i, docId := range docIds {
+		if doc, ok := docs[docId]; ok {
+			res[i] = doc
+		}
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code": http.StatusOK,
+		"msg":  "OK",
+		"docs": res,
+	})
+}
+
+// 索引库不存在时返回404
+func getDocsStatus(err error) int {
+	if err == indexer.ErrIndexNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
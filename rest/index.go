@@ -1,15 +1,19 @@
 package rest
 
 import (
+	"fmt"
 	"go-search/indexer"
 	"net/http"
+	"strconv"
 
 	helper "github.com/rosbit/http-helper"
 )
 
-// PUT /doc/:index
+// PUT /doc/:index[?if_version=N]
 //
 // add one document to index
+// with if_version, the current version of the document must be N, or 409 is returned.
+// if_version=0 means the document must not exist.
 //
 // POST body:
 // {
@@ -20,16 +24,28 @@ func IndexDoc(c *helper.Context) {
 	updateDoc(c, indexer.IndexDoc, "doc added to index")
 }
 
-// PUT /update/:index
+// PUT /update/:index[?upsert][&if_version=N]
 //
 // update an existing document. there must be pk fields in the body.
+// fields are set first, then operators are applied to the stored doc atomically.
+// with upsert, the document will be created if it doesn't exist.
+// with if_version, the current version of the document must be N, or 409 is returned.
 //
 // POST body:
 // {
 //   "field-name": "xxx",
 //   ...
+//   "$set": {"field-name": "xxx", ...},
+//   "$inc": {"field-name": 1, ...},
+//   "$unset": ["field-name", ...],
+//   "$append": {"field-name": "tag1 tag2", ...},
+//   "$remove": {"field-name": "tag1 tag2", ...}
 // }
 func UpdateDoc(c *helper.Context) {
+	if _, upsert := c.QueryParams()["upsert"]; upsert {
+		updateDoc(c, indexer.UpsertDoc, "doc upserted to index")
+		return
+	}
 	updateDoc(c, indexer.UpdateDoc, "doc updated to index")
 }
 
@@ -41,21 +57,54 @@ func updateDoc(c *helper.Context, fnUpdateDoc indexer.FnUpdateDoc, okStr string)
 		_ = c.Error(code, err.Error())
 		return
 	}
-	docID, err := fnUpdateDoc(index, doc)
+	ifVersion, err := getIfVersion(c)
+	if err != nil {
+		_ = c.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+	docID, version, err := fnUpdateDoc(index, doc, ifVersion)
 	if err != nil {
-		_ = c.Error(http.StatusInternalServerError, err.Error())
+		_ = c.Error(errorStatus(err), err.Error())
 		return
 	}
 	_ = c.JSON(http.StatusOK, map[string]interface{}{
-		"code": http.StatusOK,
-		"msg":  okStr,
-		"id":   docID,
+		"code":    http.StatusOK,
+		"msg":     okStr,
+		"id":      docID,
+		"version": version,
 	})
 }
 
-// PUT /docs/:index[?cb=url-encoded-callback-url]
+// 解析if_version参数，没有该参数时返回nil
+func getIfVersion(c *helper.Context) (*uint64, error) {
+	if _, ok := c.QueryParams()["if_version"]; !ok {
+		return nil, nil
+	}
+	version, err := strconv.ParseUint(c.QueryParam("if_version"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad if_version")
+	}
+	return &version, nil
+}
+
+// 版本冲突返回409，doc不存在返回404，请求的参数或doc不合法返回400，其它错误返回500
+func errorStatus(err error) int {
+	switch {
+	case err == indexer.ErrVersionConflict:
+		return http.StatusConflict
+	case err == indexer.ErrDocNotFound:
+		return http.StatusNotFound
+	case indexer.IsBadRequest(err):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// PUT /docs/:index[?cb=url-encoded-callback-url|async]
 //
 // add 1 or more documents to index
+// with cb or async, documents are added in background and a job id is returned,
+// see GET /jobs/:id for the job status.
 //
 // path parameter
 //  - index  name of index
@@ -109,8 +158,9 @@ func IndexDocs(c *helper.Context) {
 	}
 
 	cb := c.QueryParam("cb")
-	if cb == "" {
-		docIds, err := indexReader(index, in)
+	_, async := c.QueryParams()["async"]
+	if cb == "" && !async {
+		docIds, _, err := indexReader(index, in)
 		if err != nil && docIds != nil {
 			_ = c.Error(http.StatusInternalServerError, err.Error())
 			return
@@ -126,10 +176,15 @@ func IndexDocs(c *helper.Context) {
 			_ = c.Error(http.StatusInternalServerError, err.Error())
 			return
 		}
-		indexReader(index, inTmp, cb, tmpName)
+		_, jobId, err := indexReader(index, inTmp, cb, tmpName)
+		if err != nil {
+			_ = c.Error(http.StatusInternalServerError, err.Error())
+			return
+		}
 		_ = c.JSON(http.StatusOK, map[string]interface{}{
 			"code": http.StatusOK,
 			"msg":  "indexing request accepted",
+			"job":  jobId,
 		})
 	}
 }
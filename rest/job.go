@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"go-search/indexer"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// GET /jobs/:id
+//
+// 异步导入任务的状态
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "OK",
+//   "job": {
+//      "id": "job-id",
+//      "index": "index-name",
+//      "state": "running|done|canceled",
+//      "docs": 100,  // 导入成功的doc数
+//      "failed": 1,  // 导入失败的doc数
+//      "errors": [{"doc": 3, "error": "xxx"}], // 最多100个，doc为第几个doc，从1开始，不是行号
+//      "start-time": "2019-10-10 19:01:48",
+//      "end-time": "2019-10-10 19:01:50",
+//      "elapsed": 2.1
+//   }
+// }
+func GetJob(c *helper.Context) {
+	job, err := indexer.GetJob(c.Param("id"))
+	if err != nil {
+		_ = c.Error(http.StatusNotFound, err.Error())
+		return
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code": http.StatusOK,
+		"msg":  "OK",
+		"job":  job,
+	})
+}
+
+// DELETE /jobs/:id
+//
+// 取消正在执行的异步导入任务，已经导入的doc不会删除
+func CancelJob(c *helper.Context) {
+	id := c.Param("id")
+	if _, err := indexer.GetJob(id); err != nil {
+		_ = c.Error(http.StatusNotFound, err.Error())
+		return
+	}
+	if err := indexer.CancelJob(id); err != nil {
+		_ = c.Error(http.StatusConflict, err.Error())
+		return
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code": http.StatusOK,
+		"msg":  "job canceled",
+		"id":   id,
+	})
+}
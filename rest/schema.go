@@ -42,7 +42,7 @@ func CreateSchema(c *helper.Context) {
 	}
 	defer jsonFile.Close()
 
-	if err := conf.SaveSchema(index, jsonFile); err != nil {
+	if err := indexer.SaveSchema(index, jsonFile); err != nil {
 		_ = c.Error(http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -17,15 +17,34 @@ import (
 //
 // query arguments:
 //  q:  查询条件，+xxx:必出现、-xxx"必不出现、xxx:可以出现
+//      支持括号、AND/OR/NOT和"字段名:xxx"，如q=(red OR blue) AND -(used) AND brand:acme
+//      加引号的是短语，"xxx yyy"~N 表示短语中的词间隔之和不超过N
+//      xxx* 前缀查询，含有'*'/'?'的是通配符查询，xxx~N 是编辑距离不超过N的模糊查询
 //  fq: 指定字段的q，格式为"字段名:q"，多个fq间用','或';'分割，如fq=name:rosbit;age:10
-//  s:  排序字段，格式为"字段名[:desc|asc]"，多个s间用','或';'分割，如s=name;age:asc
+//  s:  排序字段，格式为"字段名[:desc|asc[:bytes|pinyin]]"，多个s间用','或';'分割，如s=name;age:asc
+//      字符串字段按字节序或拼音排序，如s=title:asc:pinyin
 //  f:  过滤，支持区间，格式为"字段名:val1,val2,min~max"，min/max可以只出现一个，多个f间用'|'分割，
 //  f 示例 f=name:rosbit,bitros;age:10,16~20,~8,30~
 //  page: 页码，从1开始
 //  pagesize: 每页条数，最大100
+//  after: 游标分页，值为上一页pagination中的after，为空时从第一个结果开始，有after时忽略page
 //  fl: 输出字段列表，多个字段名用','分割
+//  facet: 统计字段列表，多个字段名用','分割，统计所有满足q/fq/f的doc中各字段值出现的doc数
+//  facetsize: 每个facet字段输出的值个数，缺省10
+//  agg: 数值、时间字段的聚合，格式为"字段名:类型[:参数]"，多个agg间用'|'分割
+//       range:0~100,100~500 区间统计，包含下界、不包含上界
+//       histogram:100 固定间隔统计
+//       date_histogram:day|week|month 按自然日、周、月统计，使用配置的时区
+//       stats 输出count/min/max/avg/sum
+//  hl: 高亮字段列表，多个字段名用','分割，每个doc的"_highlight"中输出匹配q/fq的片段
+//  hl.pre, hl.post: 高亮标签，缺省为<em>、</em>
+//  hl.fragsize: 片段长度(字符数)，缺省100，0表示输出整个字段
+//  hl.snippets: 每个字段最多输出的片段数，缺省3
+//  explain: 是否在每个doc的"_explain"中输出docID、各排序字段的值及打分、用于排序的打分
 //  pretty: 是否美化输出结果，如果没有该参数，则紧凑输出
 //
+// q/fq/s/f/agg等参数不合法或after不是当前排序条件的游标时返回400
+//
 // 返回结果:
 // {
 //   "code": 200,
@@ -33,15 +52,24 @@ import (
 //   "result":{
 //      "timeout": false
 //      "docs":[
-//          {doc1}, {doc2}, ...
+//          {doc1}, {doc2}, ...  // 有hl参数时doc中有"_highlight": {"f1": ["...<em>xxx</em>..."]}
 //      },
 //      "pagination":{
 //        "total": 5,
 //        "pages": 1,
 //        "page-size": 20,
 //        "curr-page": 1,
-//        "page-count": 5
+//        "page-count": 5,
+//        "after": "xxx"  // 有after参数且还有结果时才输出，作为下一页的after参数
+//      },
+//      "facets":{  // 有facet参数时才输出
+//        "f1": [{"value": v1, "count": 3}, {"value": v2, "count": 2}, ...]
 //      },
+//      "aggs":{  // 有agg参数时才输出
+//        "f2:range": [{"from": 0, "to": 100, "count": 3}, ...],
+//        "f2:histogram:100": [{"key": 0, "count": 3}, ...],
+//        "f3:stats": {"count": 4, "min": 1, "max": 10, "avg": 5, "sum": 20}
+//      }
 //    }
 // }
 //
@@ -50,18 +78,31 @@ func Search(c *helper.Context) {
 	log.Printf("[query] %s\n", c.Request().RequestURI)
 	index := c.Param("index")
 
-	q := c.QueryParam("q")
-	fq := c.QueryParam("fq")
-	s := c.QueryParam("s")
-	f := c.QueryParam("f")
-	page := c.QueryParam("page")
-	pagesize := c.QueryParam("pagesize")
-	fl := c.QueryParam("fl")
+	args := &indexer.QueryArgs{
+		Q:          c.QueryParam("q"),
+		Fq:         c.QueryParam("fq"),
+		S:          c.QueryParam("s"),
+		F:          c.QueryParam("f"),
+		Page:       c.QueryParam("page"),
+		PageSize:   c.QueryParam("pagesize"),
+		Fl:         c.QueryParam("fl"),
+		Facet:      c.QueryParam("facet"),
+		FacetSize:  c.QueryParam("facetsize"),
+		Agg:        c.QueryParam("agg"),
+		Hl:         c.QueryParam("hl"),
+		HlPre:      c.QueryParam("hl.pre"),
+		HlPost:     c.QueryParam("hl.post"),
+		HlFragSize: c.QueryParam("hl.fragsize"),
+		HlSnippets: c.QueryParam("hl.snippets"),
+	}
+	_, args.Explain = c.QueryParams()["explain"]
+	_, args.HasAfter = c.QueryParams()["after"]
+	args.After = c.QueryParam("after")
 	_, pretty := c.QueryParams()["pretty"]
 
-	pagination, timeout, docs, err := indexer.Query(index, q, fq, s, f, page, pagesize, fl)
+	res, err := indexer.Query(index, args)
 	if err != nil {
-		_ = c.Error(http.StatusInternalServerError, err.Error())
+		_ = c.Error(errorStatus(err), err.Error())
 		return
 	}
 
@@ -69,17 +110,26 @@ func Search(c *helper.Context) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if !pretty {
-		outputJSONDocByDoc(w, pagination, timeout, docs)
+		outputJSONDocByDoc(w, res)
 	} else {
-		prettyOutputJSONDocByDoc(w, pagination, timeout, docs)
+		prettyOutputJSONDocByDoc(w, res)
 	}
 }
 
-func outputJSONDocByDoc(w http.ResponseWriter, pagination interface{}, timeout bool, docs <-chan interface{}) {
+func outputJSONDocByDoc(w http.ResponseWriter, res *indexer.QueryResult) {
 	je := json.NewEncoder(w)
+	docs := res.Docs
 
-	fmt.Fprintf(w, `{"code":%d,"msg":"OK","result":{"timeout":%v,"pagination":`, http.StatusOK, timeout)
-	_ = je.Encode(pagination)
+	fmt.Fprintf(w, `{"code":%d,"msg":"OK","result":{"timeout":%v,"pagination":`, http.StatusOK, res.Timeout)
+	_ = je.Encode(res.Pagination)
+	if res.Facets != nil {
+		fmt.Fprintf(w, `,"facets":`)
+		_ = je.Encode(res.Facets)
+	}
+	if res.Aggs != nil {
+		fmt.Fprintf(w, `,"aggs":`)
+		_ = je.Encode(res.Aggs)
+	}
 	fmt.Fprintf(w, `,"docs":`)
 	count := 0
 	if docs != nil {
@@ -103,18 +153,32 @@ func outputJSONDocByDoc(w http.ResponseWriter, pagination interface{}, timeout b
 	fmt.Fprintf(w, "}}")
 }
 
-func prettyOutputJSONDocByDoc(w http.ResponseWriter, pagination interface{}, timeout bool, docs <-chan interface{}) {
+func prettyOutputJSONDocByDoc(w http.ResponseWriter, res *indexer.QueryResult) {
+	docs := res.Docs
 	fmt.Fprintf(w,
 		`{
   "code": %d,
   "msg": "OK",
   "result": {
     "timeout": %v,
-    "pagination": `, http.StatusOK, timeout)
+    "pagination": `, http.StatusOK, res.Timeout)
 
-	b, _ := json.MarshalIndent(pagination, "    ", "    ")
+	b, _ := json.MarshalIndent(res.Pagination, "    ", "    ")
 	_, _ = w.Write(b)
 
+	if res.Facets != nil {
+		_, _ = io.WriteString(w, `,
+    "facets": `)
+		b, _ = json.MarshalIndent(res.Facets, "    ", "    ")
+		_, _ = w.Write(b)
+	}
+	if res.Aggs != nil {
+		_, _ = io.WriteString(w, `,
+    "aggs": `)
+		b, _ = json.MarshalIndent(res.Aggs, "    ", "    ")
+		_, _ = w.Write(b)
+	}
+
 	_, _ = io.WriteString(w, `,
     "docs": `)
 
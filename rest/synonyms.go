@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"go-search/indexer"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// PUT /synonyms/:index
+//
+// 设置索引库的同义词，替换原来的同义词，立即生效，不需要重建索引
+//
+// path parameter
+//  - index  name of index
+// PUT body:
+// [
+//    "tv, television, telly",  // 同义词组，查询任何一个词时都查询组中所有的词
+//    "ipod, i-pod => ipod",     // 单向映射，查询左边的词时改为查询右边的词
+//    ...
+// ]
+// 空数组表示删除所有同义词
+func SetSynonyms(c *helper.Context) {
+	index := c.Param("index")
+
+	var rules []string
+	if code, err := c.ReadJSON(&rules); err != nil {
+		_ = c.Error(code, err.Error())
+		return
+	}
+	if err := indexer.SetSynonyms(index, rules); err != nil {
+		_ = c.Error(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":  http.StatusOK,
+		"msg":   "synonyms updated",
+		"index": index,
+		"count": len(rules),
+	})
+}
+
+// GET /synonyms/:index
+//
+// 获取索引库的同义词规则
+//
+// path parameter
+//  - index  name of index
+func GetSynonyms(c *helper.Context) {
+	index := c.Param("index")
+
+	rules, err := indexer.GetSynonyms(index)
+	if err != nil {
+		_ = c.Error(http.StatusNotFound, err.Error())
+		return
+	}
+	if rules == nil {
+		rules = []string{}
+	}
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":     http.StatusOK,
+		"msg":      "OK",
+		"synonyms": rules,
+	})
+}
+
+// DELETE /synonyms/:index
+//
+// 删除索引库的所有同义词
+//
+// path parameter
+//  - index  name of index
+func DeleteSynonyms(c *helper.Context) {
+	index := c.Param("index")
+
+	if err := indexer.SetSynonyms(index, nil); err != nil {
+		_ = c.Error(http.StatusNotFound, err.Error())
+		return
+	}
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":  http.StatusOK,
+		"msg":   "synonyms deleted",
+		"index": index,
+	})
+}
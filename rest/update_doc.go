@@ -0,0 +1,67 @@
+package rest
+
+import (
+	"go-search/indexer"
+	"log"
+	"net/http"
+
+	helper "github.com/rosbit/http-helper"
+)
+
+// POST /update_by_query/:index?q=+xxx&fq=f:q-in-field&f=f1:xxx|f2:r1~r2
+//
+// 修改所有满足条件的doc
+//
+// query arguments:
+//  q, fq, f: 同/search/:index，q=*时匹配所有doc，没有任何条件时返回400
+//  all: 为true时相当于q=*
+//
+// 查询参数或修改的操作符不合法时返回400
+//
+// POST body: 操作符同PUT /update/:index，不能有普通字段
+// {
+//   "$set": {"field-name": "xxx", ...},      // 设置字段值
+//   "$inc": {"field-name": 10, ...},         // 数值字段加上给定的值，可以为负数
+//   "$unset": ["field-name", ...],           // 删除字段
+//   "$append": {"field-name": "t1 t2", ...}, // 空格分词的字段追加标签，已有的标签不重复追加
+//   "$remove": {"field-name": "t1 t2", ...}  // 空格分词的字段删除标签
+// }
+//
+// 返回结果:
+// {
+//   "code": 200,
+//   "msg": "docs updated to index",
+//   "updated": 5,
+//   "failed": 0
+// }
+func UpdateByQuery(c *helper.Context) {
+	log.Printf("[update_by_query] %s\n", c.Request().RequestURI)
+	index := c.Param("index")
+
+	var updates map[string]interface{}
+	if code, err := c.ReadJSON(&updates); err != nil {
+		_ = c.Error(code, err.Error())
+		return
+	}
+
+	args := &indexer.QueryArgs{
+		Q:  c.QueryParam("q"),
+		Fq: c.QueryParam("fq"),
+		F:  c.QueryParam("f"),
+	}
+	if c.QueryParam("all") == "true" && args.Q == "" {
+		args.Q = indexer.MatchAll
+	}
+	updated, failed, err := indexer.UpdateByQuery(index, args, updates)
+	if err != nil {
+		_ = c.Error(errorStatus(err), err.Error())
+		return
+	}
+
+	_ = c.JSON(http.StatusOK, map[string]interface{}{
+		"code":    http.StatusOK,
+		"msg":     "docs updated to index",
+		"updated": updated,
+		"failed":  failed,
+	})
+}
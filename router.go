@@ -20,6 +20,12 @@ import (
 
 // 设置路由，进入服务状态
 func StartService() error {
+	if err := indexer.InitSegmenter(); err != nil {
+		return err
+	}
+	if err := indexer.InitVersion(); err != nil {
+		return err
+	}
 	initIndexers()
 
 	api := helper.NewHelper()
@@ -28,12 +34,25 @@ func StartService() error {
 	_ = api.POST("/schema/:index", rest.CreateSchema)
 	_ = api.DELETE("/schema/:index", rest.DeleteSchema)
 	_ = api.PUT("/schema/:index/:newIndex", rest.RenameSchema)
+	_ = api.GET("/synonyms/:index", rest.GetSynonyms)
+	_ = api.PUT("/synonyms/:index", rest.SetSynonyms)
+	_ = api.DELETE("/synonyms/:index", rest.DeleteSynonyms)
 	_ = api.PUT("/doc/:index", rest.IndexDoc)
 	_ = api.PUT("/docs/:index", rest.IndexDocs)
 	_ = api.PUT("/update/:index", rest.UpdateDoc)
+	_ = api.POST("/update_by_query/:index", rest.UpdateByQuery)
 	_ = api.DELETE("/doc/:index", rest.DeleteDoc)
+	_ = api.GET("/doc/:index/:id", rest.GetDoc)
+	_ = api.HEAD("/doc/:index/:id", rest.HasDoc)
+	_ = api.POST("/mget/:index", rest.MultiGetDocs)
 	_ = api.DELETE("/docs/:index", rest.DeleteDocs)
+	_ = api.POST("/delete_by_query/:index", rest.DeleteByQuery)
+	_ = api.POST("/bulk", rest.Bulk)
+	_ = api.GET("/jobs/:id", rest.GetJob)
+	_ = api.DELETE("/jobs/:id", rest.CancelJob)
 	_ = api.GET("/search/:index", rest.Search)
+	_ = api.GET("/export/:index", rest.Export)
+	_ = api.GET("/count/:index", rest.Count)
 
 	// health check
 	_ = api.GET("/health", func(c *helper.Context) {